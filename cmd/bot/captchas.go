@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jqs7/drei/pkg/captcha"
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// 自行实现的验证码在其包的 init 中调用 captcha.Register 注册，
+// 在本目录中新增文件空白导入该包，并将名称加入 CAPTCHAS 即可启用
+
+// textCaptchaCharset 读取字母数字验证码的字符集，未设置时使用默认字符集
+func textCaptchaCharset() string {
+	if v := os.Getenv("TEXT_CAPTCHA_CHARSET"); v != "" {
+		return v
+	}
+	return captcha.DefaultTextCharset
+}
+
+// renderOptions 读取 CAPTCHA_RENDER_CONFIG 指定的验证码绘制参数，未设置时使用默认参数
+func renderOptions() map[string]captcha.RenderOptions {
+	path := os.Getenv("CAPTCHA_RENDER_CONFIG")
+	if path == "" {
+		return nil
+	}
+	opts, err := captcha.LoadRenderOptions(path)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	return opts
+}
+
+// captchaConfigs 读取 CAPTCHA_CONFIG 指定的以验证码名称为键的 JSON 配置，
+// 未在其中配置的内置验证码按环境变量生成配置
+func captchaConfigs() map[string]json.RawMessage {
+	configs := map[string]json.RawMessage{}
+	if path := os.Getenv("CAPTCHA_CONFIG"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open %s: %v", path, err)
+		}
+		if err := json.NewDecoder(f).Decode(&configs); err != nil {
+			log.Fatalf("decode %s: %v", path, err)
+		}
+		f.Close()
+	}
+
+	renderOpts := renderOptions()
+	// 未设置 IDIOM_PATH 及 FONT_PATH 时使用内置的词典及字体
+	fontPath := os.Getenv("FONT_PATH")
+	defaults := map[string]interface{}{
+		model.CaptchaTypeIdiom: captcha.IdiomConfig{
+			IdiomPath:     os.Getenv("IDIOM_PATH"),
+			FontPath:      fontPath,
+			MaxDifficulty: envInt("IDIOM_MAX_DIFFICULTY", model.IdiomDifficultyHard),
+			Render:        renderOpts[model.CaptchaTypeIdiom],
+		},
+		model.CaptchaTypeMath: captcha.MathConfig{
+			FontPath: fontPath,
+			Render:   renderOpts[model.CaptchaTypeMath],
+		},
+		model.CaptchaTypeText: captcha.TextConfig{
+			FontPath: fontPath,
+			Length:   envInt("TEXT_CAPTCHA_LENGTH", captcha.DefaultTextLength),
+			Charset:  textCaptchaCharset(),
+			Render:   renderOpts[model.CaptchaTypeText],
+		},
+	}
+	for name, v := range defaults {
+		if _, ok := configs[name]; ok {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			log.Fatalf("encode %s config: %v", name, err)
+		}
+		configs[name] = b
+	}
+	return configs
+}
+
+// enabledCaptchas 读取以逗号分隔的 CAPTCHAS，未设置时启用全部已注册的验证码，
+// 第一个验证码为群组所选验证码未启用时的默认验证码
+func enabledCaptchas() []string {
+	v := os.Getenv("CAPTCHAS")
+	if v == "" {
+		return captcha.Names()
+	}
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		log.Fatalf("CAPTCHAS 未包含任何验证码")
+	}
+	return names
+}
+
+// newCaptchas 按名称创建已启用的验证码，poolSize 为每种验证码预先生成的图片数量
+func newCaptchas(names []string, poolSize int) map[string]captcha.Interface {
+	configs := captchaConfigs()
+	captchas := make(map[string]captcha.Interface, len(names))
+	for _, name := range names {
+		c, err := captcha.New(name, configs[name])
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		captchas[name] = captcha.NewPool(name, c, poolSize)
+	}
+	return captchas
+}
@@ -8,17 +8,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/jqs7/drei/pkg/admin"
 	"github.com/jqs7/drei/pkg/bot"
-	"github.com/jqs7/drei/pkg/captcha"
 	"github.com/jqs7/drei/pkg/db"
 	"github.com/jqs7/drei/pkg/model"
 	"github.com/jqs7/drei/pkg/queue"
+	"github.com/jqs7/drei/pkg/utils"
 	"github.com/jqs7/drei/pkg/verifier"
 	"github.com/skip2/go-qrcode"
 )
@@ -29,6 +31,19 @@ var RespOK = &events.APIGatewayProxyResponse{
 	Body:       "True",
 }
 
+// envInt 读取整数环境变量，未设置时返回 def
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", key, v, err)
+	}
+	return n
+}
+
 func main() {
 	botAPI, err := bot.NewAPI(os.Getenv("BOT_TOKEN"))
 	if err != nil {
@@ -40,17 +55,28 @@ func main() {
 		log.Fatalln("init aws session: ", err)
 	}
 
-	idiomCaptcha, err := captcha.NewRandIdiomCaptcha("/opt/idiom.json", "/opt/fonts")
+	captchaTypes := enabledCaptchas()
+	// CAPTCHA_POOL_SIZE 为每种验证码预先生成的图片数量，为 0 时不预先生成
+	captchas := newCaptchas(captchaTypes, envInt("CAPTCHA_POOL_SIZE", 10))
+
+	blacklist, closeBlacklist, err := db.NewBlacklistFromEnv(sess)
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-
+	utils.CloseOnSignal(closeBlacklist)
+	settings := db.NewSettings(sess, os.Getenv("SETTINGS_TABLE_NAME"))
+	recorder := verifier.Recorder{
+		Audit: db.NewAudit(sess, os.Getenv("AUDIT_TABLE_NAME")),
+		Stats: db.NewStats(sess, os.Getenv("STATS_TABLE_NAME")),
+	}
 	idiomVerifier, err := verifier.NewIdiomVerifier(botAPI, queue.NewSQS(sess),
-		db.NewBlacklist(sess, os.Getenv("USERS_TABLE_NAME")), idiomCaptcha,
+		blacklist, settings, recorder,
+		captchas, captchaTypes[0],
 	)
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
+	groupAdmin := admin.NewGroupAdmin(botAPI, settings, recorder.Audit, recorder.Stats, captchaTypes)
 
 	lambda.Start(func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 		switch req.Path {
@@ -63,6 +89,16 @@ func main() {
 			if update.CallbackQuery != nil {
 				switch update.CallbackQuery.Message.Chat.Type {
 				case "group", "supergroup":
+					if admin.IsCallback(update.CallbackQuery.Data) {
+						groupAdmin.OnCallbackQuery(ctx,
+							update.CallbackQuery.Message.Chat.ID,
+							update.CallbackQuery.Message.MessageID,
+							update.CallbackQuery.From.ID,
+							update.CallbackQuery.ID,
+							update.CallbackQuery.Data,
+						)
+						break
+					}
 					idiomVerifier.OnCallbackQuery(ctx,
 						update.CallbackQuery.Message.Chat.ID,
 						update.CallbackQuery.Message.MessageID,
@@ -81,7 +117,7 @@ func main() {
 					if err != nil {
 						log.Fatalln(err)
 					}
-					botAPI.UpdatePhoto(
+					botAPI.UpdateCachedPhoto(
 						update.CallbackQuery.Message.Chat.ID,
 						update.CallbackQuery.Message.MessageID,
 						model.DonateMsg,
@@ -100,7 +136,9 @@ func main() {
 			}
 
 			if update.Message.NewChatMembers != nil {
-				botAPI.DeleteMsg(update.Message.Chat.ID, update.Message.MessageID)
+				if verifier.GetSettings(ctx, settings, update.Message.Chat.ID).DeleteJoinMsg {
+					botAPI.DeleteMsg(update.Message.Chat.ID, update.Message.MessageID)
+				}
 				for _, v := range *update.Message.NewChatMembers {
 					if v.IsBot {
 						continue
@@ -120,12 +158,36 @@ func main() {
 
 			switch update.Message.Chat.Type {
 			case "group", "supergroup":
+				// 进群、退群的系统消息不是用户的回答
+				if update.Message.NewChatMembers != nil || update.Message.LeftChatMember != nil {
+					return RespOK, nil
+				}
 				idiomVerifier.Verify(ctx,
 					update.Message.Chat.ID,
 					update.Message.From.ID,
 					update.Message.MessageID,
 					update.Message.Text,
 				)
+				if update.Message.IsCommand() {
+					groupAdmin.OnCommand(ctx,
+						update.Message.Chat.ID,
+						update.Message.From.ID,
+						update.Message.MessageID,
+						update.Message.Command(),
+						update.Message.CommandArguments(),
+					)
+				}
+				if update.Message.Document != nil {
+					groupAdmin.OnDocument(ctx,
+						update.Message.Chat.ID,
+						update.Message.From.ID,
+						update.Message.MessageID,
+						update.Message.Caption,
+						update.Message.Document.FileID,
+						update.Message.Document.FileName,
+						update.Message.Document.FileSize,
+					)
+				}
 			case "private":
 				switch update.Message.Text {
 				case "/help", "/start":
@@ -139,7 +201,7 @@ func main() {
 					if err != nil {
 						log.Fatalln(err)
 					}
-					_, _ = botAPI.SendImg(update.Message.Chat.ID,
+					_, _ = botAPI.SendCachedImg(update.Message.Chat.ID,
 						b, model.DonateMsg,
 						model.DonatesKeyboard(model.CallbackTypeDonateWX),
 					)
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
 	"time"
@@ -16,7 +15,9 @@ import (
 	"github.com/jqs7/drei/pkg/bot"
 	"github.com/jqs7/drei/pkg/db"
 	"github.com/jqs7/drei/pkg/model"
+	"github.com/jqs7/drei/pkg/utils"
 	"github.com/jqs7/drei/pkg/verifier"
+	"golang.org/x/xerrors"
 )
 
 func main() {
@@ -31,7 +32,17 @@ func main() {
 	}
 	svc := sqs.New(sess)
 	queueName := aws.String(os.Getenv("CAPTCHA_COUNTDOWN_QUEUE"))
-	blacklist := db.NewBlacklist(sess, os.Getenv("USERS_TABLE_NAME"))
+	blacklist, closeBlacklist, err := db.NewBlacklistFromEnv(sess)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	utils.CloseOnSignal(closeBlacklist)
+	settings := db.NewSettings(sess, os.Getenv("SETTINGS_TABLE_NAME"))
+	recorder := verifier.Recorder{
+		Audit: db.NewAudit(sess, os.Getenv("AUDIT_TABLE_NAME")),
+		Stats: db.NewStats(sess, os.Getenv("STATS_TABLE_NAME")),
+	}
+	secret := verifier.CallbackSecret()
 
 	lambda.Start(func(ctx context.Context, req events.SQSEvent) error {
 		for _, v := range req.Records {
@@ -43,14 +54,26 @@ func main() {
 			item, err := blacklist.GetItem(ctx, msg.ChatID, msg.UserID)
 			if err != nil {
 				if err == db.ErrNotFound {
-					return nil
+					continue
+				}
+				// 损坏的记录重试也无法恢复，跳过以免阻塞队列
+				if xerrors.Is(err, db.ErrMalformedItem) {
+					log.Printf("skip malformed item %d %d: %+v", msg.ChatID, msg.UserID, err)
+					continue
 				}
 				log.Println(err)
 				return err
 			}
 			if botAPI.HasLeft(msg.ChatID, msg.UserID) {
+				if err := blacklist.DeleteItem(ctx, msg.ChatID, msg.UserID, item.MsgID); err != nil {
+					if err == db.ErrNotFound {
+						continue
+					}
+					log.Println(err)
+					return err
+				}
 				botAPI.DeleteMsg(msg.ChatID, item.MsgID)
-				blacklist.DeleteItem(ctx, msg.ChatID, msg.UserID)
+				recorder.Record(ctx, msg.ChatID, msg.UserID, msg.UserID, model.AuditEventLeave)
 				continue
 			}
 			var delay int64 = model.CaptchaRefreshSecond
@@ -58,15 +81,20 @@ func main() {
 				delay = secToExpire
 			}
 			if item.ExpireAt.Before(time.Now()) || delay <= 0 {
+				// 删除成功才移出用户，若用户已通过验证或被管理员处理则跳过，删除失败时交由 SQS 重试
+				if err := blacklist.DeleteItem(ctx, msg.ChatID, msg.UserID, item.MsgID); err != nil {
+					if err == db.ErrNotFound {
+						continue
+					}
+					log.Println(err)
+					return err
+				}
 				botAPI.DeleteMsg(msg.ChatID, item.MsgID)
-				botAPI.Kick(msg.ChatID, msg.UserID, time.Now().Add(time.Minute))
-				blacklist.DeleteItem(ctx, msg.ChatID, msg.UserID)
+				botAPI.Kick(msg.ChatID, msg.UserID, verifier.BanUntil(verifier.GetSettings(ctx, settings, msg.ChatID)))
+				recorder.Record(ctx, msg.ChatID, msg.UserID, 0, model.AuditEventTimeoutKick)
 				continue
 			}
-			botAPI.UpdateCaption(msg.ChatID, item.MsgID,
-				fmt.Sprintf(item.UserLink+" "+item.MsgTemplate, time.Until(item.ExpireAt)/time.Second),
-				verifier.InlineKeyboard,
-			)
+			botAPI.UpdateCaption(msg.ChatID, item.MsgID, verifier.Caption(*item, time.Until(item.ExpireAt)), verifier.Keyboard(*item, secret))
 			_, err = svc.SendMessageWithContext(ctx, &sqs.SendMessageInput{
 				DelaySeconds: &delay,
 				MessageBody:  aws.String(v.Body),
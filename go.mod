@@ -1,17 +1,18 @@
 module github.com/jqs7/drei
 
-go 1.13
+go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.11.4
 	github.com/aws/aws-lambda-go v1.13.3
 	github.com/aws/aws-sdk-go v1.28.4
+	github.com/go-redis/redis/v7 v7.2.0
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/golang/mock v1.4.0
-	github.com/hanguofeng/gocaptcha v1.0.7
+	github.com/hanguofeng/freetype-go-mirror v0.0.0-20140928112427-cfb10e2cb6de
 	github.com/skip2/go-qrcode v0.0.0-20191027152451-9434209cb086
 	github.com/stretchr/testify v1.4.0
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
 )
-
-replace github.com/hanguofeng/gocaptcha v1.0.7 => github.com/jqs7/gocaptcha v1.0.8-0.20181014100812-c7bcbe23fde4
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jqs7/drei/pkg/bot"
+	"github.com/jqs7/drei/pkg/captcha"
+	"github.com/jqs7/drei/pkg/db"
+	"github.com/jqs7/drei/pkg/model"
+)
+
+type Interface interface {
+	OnCommand(ctx context.Context, chatID int64, fromUser, msgID int, command, args string)
+	OnCallbackQuery(ctx context.Context, chatID int64, msgID, fromUser int, callbackID, data string)
+	// OnDocument 处理群组中的文件消息，caption 为文件说明
+	OnDocument(ctx context.Context, chatID int64, fromUser, msgID int, caption, fileID, fileName string, fileSize int)
+}
+
+// IsCallback 判断按钮回调是否应交由群组管理处理
+func IsCallback(data string) bool {
+	return strings.HasPrefix(data, model.CallbackTypeSettings)
+}
+
+type GroupAdmin struct {
+	bot          bot.Interface
+	settings     db.ISettings
+	audit        db.IAudit
+	stats        db.IStats
+	captchaTypes []string
+}
+
+// NewGroupAdmin 的 captchaTypes 为已启用的验证码名称，群组设置按此顺序切换验证码类型，
+// 为空时可切换全部已注册的验证码
+func NewGroupAdmin(bot bot.Interface, settings db.ISettings, audit db.IAudit, stats db.IStats, captchaTypes []string) Interface {
+	if len(captchaTypes) == 0 {
+		captchaTypes = captcha.Names()
+	}
+	return &GroupAdmin{
+		bot:          bot,
+		settings:     settings,
+		audit:        audit,
+		stats:        stats,
+		captchaTypes: captchaTypes,
+	}
+}
+
+func (ga GroupAdmin) OnCommand(ctx context.Context, chatID int64, fromUser, msgID int, command, args string) {
+	switch command {
+	case model.CommandSettings:
+		if ga.authorize(chatID, fromUser, msgID) {
+			ga.onSettings(ctx, chatID, args)
+		}
+	case model.CommandHistory:
+		if ga.authorize(chatID, fromUser, msgID) {
+			ga.onHistory(ctx, chatID, args)
+		}
+	case model.CommandStats:
+		if ga.authorize(chatID, fromUser, msgID) {
+			ga.onStats(ctx, chatID, time.Now())
+		}
+	}
+}
+
+// OnDocument 只处理说明为 /settings words 的文件，其余文件消息忽略
+func (ga GroupAdmin) OnDocument(ctx context.Context, chatID int64, fromUser, msgID int, caption, fileID, fileName string, fileSize int) {
+	command, args := parseCommand(caption)
+	if command != model.CommandSettings || strings.TrimSpace(args) != "words" {
+		return
+	}
+	if ga.authorize(chatID, fromUser, msgID) {
+		ga.onWordsFile(ctx, chatID, fileID, fileName, fileSize)
+	}
+}
+
+// parseCommand 按 /command@bot args 的格式解析文件说明中的命令及参数，
+// tgbotapi 只解析消息正文中的命令，不是命令时返回空字符串
+func parseCommand(text string) (command, args string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	sub := strings.SplitN(text[1:], " ", 2)
+	command = sub[0]
+	if i := strings.Index(command, "@"); i != -1 {
+		command = command[:i]
+	}
+	if len(sub) > 1 {
+		args = sub[1]
+	}
+	return command, args
+}
+
+// authorize 删除管理命令消息以保持群组整洁，并判断发送者是否为管理员
+func (ga GroupAdmin) authorize(chatID int64, fromUser, msgID int) bool {
+	ga.bot.DeleteMsg(chatID, msgID)
+	return ga.bot.IsAdmin(chatID, fromUser)
+}
+
+func (ga GroupAdmin) OnCallbackQuery(ctx context.Context, chatID int64, msgID, fromUser int, callbackID, data string) {
+	if !ga.bot.IsAdmin(chatID, fromUser) {
+		ga.bot.AnswerCallback(callbackID, "无权限")
+		return
+	}
+	switch {
+	case strings.HasPrefix(data, model.CallbackTypeSettings):
+		ga.onSettingsCallback(ctx, chatID, msgID, callbackID, data)
+	}
+}
@@ -0,0 +1,322 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jqs7/drei/pkg/bot"
+	"github.com/jqs7/drei/pkg/db"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	newAdmin := func(ctrl *gomock.Controller, isAdmin bool) (*bot.MockInterface, db.ISettings, Interface) {
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().IsAdmin(int64(1), 1).Return(isAdmin).AnyTimes()
+		settings := db.NewMemorySettings()
+		return mockBot, settings, NewGroupAdmin(mockBot, settings, db.NewMemoryAudit(), db.NewMemoryStats(), nil)
+	}
+
+	t.Run("非管理员查看设置", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, admin := newAdmin(ctrl, false)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "")
+	})
+
+	t.Run("管理员查看设置", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsgWithKeyboard(int64(1), settingsText(model.DefaultChatSettings(1)), settingsKeyboard).Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "")
+	})
+
+	t.Run("管理员设置欢迎语", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(2)
+		mockBot.EXPECT().SendMsgWithKeyboard(int64(1), gomock.Any(), settingsKeyboard).Times(2)
+
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "welcome  欢迎光临 ")
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "欢迎光临", s.WelcomeMsg)
+
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "welcome")
+		s, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "", s.WelcomeMsg)
+	})
+
+	t.Run("管理员设置过长的欢迎语", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsg(int64(1), fmt.Sprintf(model.WelcomeTooLongMsg, model.MaxWelcomeMsgLen+1, model.MaxWelcomeMsgLen)).Times(1)
+
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "welcome "+strings.Repeat("欢", model.MaxWelcomeMsgLen+1))
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "", s.WelcomeMsg)
+	})
+
+	t.Run("管理员设置自定义词库", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(3)
+		mockBot.EXPECT().SendMsgWithKeyboard(int64(1), gomock.Any(), settingsKeyboard).Times(2)
+		mockBot.EXPECT().SendMsg(int64(1), gomock.Any()).Times(1)
+
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "words 一心一意，画蛇添足 守株待兔、 对牛弹琴 一心一意")
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"一心一意", "画蛇添足", "守株待兔", "对牛弹琴"}, s.Words)
+
+		// 词库无效时保留原词库
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "words 一心一意 画蛇添足")
+		s, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, s.Words, 4)
+
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "words")
+		s, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, s.Words)
+	})
+
+	t.Run("自定义词库与拼音答题互斥", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsg(int64(1), model.WordsPinyinConflictMsg).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "自定义词库的词语没有拼音，请先清空词库").Times(1)
+
+		s := model.DefaultChatSettings(1)
+		s.PinyinAnswer = true
+		assert.NoError(t, settings.PutSettings(ctx, s))
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandSettings, "words 一心一意 画蛇添足 守株待兔 对牛弹琴")
+		got, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, got.Words)
+
+		s.PinyinAnswer = false
+		s.Words = []string{"一心一意", "画蛇添足", "守株待兔", "对牛弹琴"}
+		assert.NoError(t, settings.PutSettings(ctx, s))
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsPinyin)
+		got, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.False(t, got.PinyinAnswer)
+	})
+
+	t.Run("管理员上传自定义词库文件", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(3)
+		mockBot.EXPECT().GetFile("fileID", model.MaxWordsFileSize).
+			Return([]byte("\ufeff一心一意\r\n画蛇添足\n守株待兔\n\n对牛弹琴\n"), nil).Times(1)
+		mockBot.EXPECT().SendMsgWithKeyboard(int64(1), gomock.Any(), settingsKeyboard).Times(1)
+		invalidMsg := fmt.Sprintf(model.WordsFileInvalidMsg, model.MaxWordsFileSize/1024)
+		mockBot.EXPECT().SendMsg(int64(1), invalidMsg).Times(2)
+
+		admin.OnDocument(ctx, 1, 1, 2, "/settings@drei_bot words", "fileID", "words.TXT", 50)
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"一心一意", "画蛇添足", "守株待兔", "对牛弹琴"}, s.Words)
+
+		// 文件类型或大小不符时不下载，保留原词库
+		admin.OnDocument(ctx, 1, 1, 2, "/settings words", "fileID", "words.csv", 50)
+		admin.OnDocument(ctx, 1, 1, 2, "/settings words", "fileID", "words.txt", model.MaxWordsFileSize+1)
+		s, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, s.Words, 4)
+
+		// 其他文件消息不处理
+		admin.OnDocument(ctx, 1, 1, 2, "", "fileID", "words.txt", 50)
+		admin.OnDocument(ctx, 1, 1, 2, "/settings welcome", "fileID", "words.txt", 50)
+	})
+
+	t.Run("非管理员上传自定义词库文件", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, false)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		admin.OnDocument(ctx, 1, 1, 2, "/settings words", "fileID", "words.txt", 50)
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, s.Words)
+	})
+
+	t.Run("解析自定义词库", func(t *testing.T) {
+		for _, v := range []string{
+			"一心一意 画蛇添足 守株待兔",
+			"一心一意 画蛇添足 守株待兔 对牛弹琴 abcd",
+			"一心一意 画蛇添足 守株待兔 对牛弹琴 一心一",
+		} {
+			_, err := parseWords(v)
+			assert.Error(t, err, v)
+		}
+	})
+
+	t.Run("管理员切换设置", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, settings, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().UpdateMsg(int64(1), 3, gomock.Any(), settingsKeyboard).Times(4)
+		mockBot.EXPECT().AnswerCallback("callbackID", "设置已更新").Times(4)
+
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsTimeout)
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsBanDuration)
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsDeleteJoin)
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsDifficulty)
+
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 600, s.Timeout)
+		assert.Equal(t, 600, s.BanDuration)
+		assert.False(t, s.DeleteJoinMsg)
+		assert.Equal(t, model.IdiomDifficultyEasy, s.Difficulty)
+	})
+
+	t.Run("按已启用的验证码切换类型", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().IsAdmin(int64(1), 1).Return(true).AnyTimes()
+		mockBot.EXPECT().AnswerCallback("callbackID", "设置已更新").Times(2)
+		var texts []string
+		mockBot.EXPECT().UpdateMsg(int64(1), 3, gomock.Any(), settingsKeyboard).
+			Do(func(_ int64, _ int, text string, _ [][]model.KV) {
+				texts = append(texts, text)
+			}).Times(2)
+		settings := db.NewMemorySettings()
+		admin := NewGroupAdmin(mockBot, settings, db.NewMemoryAudit(), db.NewMemoryStats(),
+			[]string{model.CaptchaTypeIdiom, model.CaptchaTypeText},
+		)
+
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsCaptchaType)
+		s, err := settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, model.CaptchaTypeText, s.CaptchaType)
+		assert.Contains(t, texts[0], model.CaptchaTypeNames[model.CaptchaTypeText])
+
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsCaptchaType)
+		s, err = settings.GetSettings(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, model.CaptchaTypeIdiom, s.CaptchaType)
+		assert.Contains(t, texts[1], model.CaptchaTypeNames[model.CaptchaTypeIdiom])
+	})
+
+	t.Run("管理员关闭设置", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, admin := newAdmin(ctrl, true)
+		mockBot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "设置已保存").Times(1)
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsClose)
+	})
+
+	t.Run("非管理员切换设置", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, admin := newAdmin(ctrl, false)
+		mockBot.EXPECT().AnswerCallback("callbackID", "无权限").Times(1)
+		admin.OnCallbackQuery(ctx, 1, 3, 1, "callbackID", model.CallbackTypeSettingsTimeout)
+	})
+
+	t.Run("管理员查询验证记录", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().IsAdmin(int64(1), 1).Return(true).AnyTimes()
+		audit := db.NewMemoryAudit()
+		admin := NewGroupAdmin(mockBot, db.NewMemorySettings(), audit, db.NewMemoryStats(), nil)
+		at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, v := range []string{model.AuditEventJoin, model.AuditEventWrongAnswer, model.AuditEventAdminKick} {
+			actorID := 3
+			if v == model.AuditEventAdminKick {
+				actorID = 1
+			}
+			assert.NoError(t, audit.AppendEvent(ctx, model.AuditEvent{
+				ChatID:  1,
+				UserID:  3,
+				ActorID: actorID,
+				Type:    v,
+				Time:    at.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(3)
+		mockBot.EXPECT().SendMsg(int64(1), "<b>用户 3 最近 2 条验证记录</b>\n"+
+			"2020-01-01 08:02:00 管理员将其踢出（操作者 1）\n"+
+			"2020-01-01 08:01:00 回答错误").Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandHistory, "3 2")
+
+		mockBot.EXPECT().SendMsg(int64(1), "<b>用户 4 最近 0 条验证记录</b>\n暂无记录").Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandHistory, "4")
+
+		mockBot.EXPECT().SendMsg(int64(1), model.HistoryUsageMsg).Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandHistory, "abc")
+	})
+
+	t.Run("管理员查看验证统计", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().IsAdmin(int64(1), 1).Return(true).AnyTimes()
+		stats := db.NewMemoryStats()
+		admin := NewGroupAdmin(mockBot, db.NewMemorySettings(), db.NewMemoryAudit(), stats, nil)
+		now := time.Now()
+		for _, v := range []struct {
+			daysAgo int
+			field   string
+		}{
+			{0, model.StatChallenged},
+			{0, model.StatPassed},
+			{3, model.StatChallenged},
+			{3, model.StatTimedOut},
+			{20, model.StatChallenged},
+			{20, model.StatKicked},
+			{20, model.StatChallenged},
+			{20, model.StatLockedOut},
+			{40, model.StatChallenged},
+		} {
+			assert.NoError(t, stats.IncrStat(ctx, 1, model.StatsDay(now.AddDate(0, 0, -v.daysAgo)), v.field))
+		}
+
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsg(int64(1), "<b>本群验证统计</b>\n"+
+			"近 1 天：验证 1 人，通过 1 人，超时 0 人，管理员踢出 0 人，错误过多移出 0 人\n"+
+			"近 7 天：验证 2 人，通过 1 人，超时 1 人，管理员踢出 0 人，错误过多移出 0 人\n"+
+			"近 30 天：验证 4 人，通过 1 人，超时 1 人，管理员踢出 1 人，错误过多移出 1 人").Times(1)
+		admin.OnCommand(ctx, 1, 1, 2, model.CommandStats, "")
+	})
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// parseHistoryArgs 解析 "用户ID [条数]" 形式的参数，条数缺省为 DefaultHistoryLimit，且不超过 MaxHistoryLimit
+func parseHistoryArgs(args string) (userID, limit int, ok bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, false
+	}
+	userID, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	limit = model.DefaultHistoryLimit
+	if len(fields) == 2 {
+		limit, err = strconv.Atoi(fields[1])
+		if err != nil || limit <= 0 {
+			return 0, 0, false
+		}
+	}
+	if limit > model.MaxHistoryLimit {
+		limit = model.MaxHistoryLimit
+	}
+	return userID, limit, true
+}
+
+func (ga GroupAdmin) onHistory(ctx context.Context, chatID int64, args string) {
+	userID, limit, ok := parseHistoryArgs(args)
+	if !ok {
+		if _, err := ga.bot.SendMsg(chatID, model.HistoryUsageMsg); err != nil {
+			log.Println("send history usage failed: ", err)
+		}
+		return
+	}
+	events, err := ga.audit.ListEvents(ctx, chatID, userID, limit)
+	if err != nil {
+		log.Println("list audit events failed: ", err)
+		return
+	}
+	if _, err := ga.bot.SendMsg(chatID, historyText(userID, events)); err != nil {
+		log.Println("send history failed: ", err)
+	}
+}
+
+func historyText(userID int, events []model.AuditEvent) string {
+	lines := make([]string, 0, len(events))
+	for _, v := range events {
+		name, ok := model.AuditEventNames[v.Type]
+		if !ok {
+			name = v.Type
+		}
+		line := v.Time.In(model.LocalZone).Format("2006-01-02 15:04:05") + " " + name
+		if v.ActorID != 0 && v.ActorID != v.UserID {
+			line += fmt.Sprintf("（操作者 %d）", v.ActorID)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "暂无记录")
+	}
+	return fmt.Sprintf(model.HistoryMsg, userID, len(events), strings.Join(lines, "\n"))
+}
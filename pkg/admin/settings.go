@@ -0,0 +1,303 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/jqs7/drei/pkg/captcha"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/jqs7/drei/pkg/utils"
+	"golang.org/x/xerrors"
+)
+
+var settingsKeyboard = [][]model.KV{
+	{
+		{K: "切换验证时限", V: model.CallbackTypeSettingsTimeout},
+		{K: "切换超时封禁", V: model.CallbackTypeSettingsBanDuration},
+	},
+	{
+		{K: "切换验证码类型", V: model.CallbackTypeSettingsCaptchaType},
+		{K: "切换删除进群消息", V: model.CallbackTypeSettingsDeleteJoin},
+	},
+	{
+		{K: "切换错误次数上限", V: model.CallbackTypeSettingsMaxAttempts},
+		{K: "切换答题方式", V: model.CallbackTypeSettingsChoiceMode},
+	},
+	{
+		{K: "切换容错匹配", V: model.CallbackTypeSettingsFuzzyMatch},
+		{K: "切换拼音答题", V: model.CallbackTypeSettingsPinyin},
+	},
+	{
+		{K: "切换成语难度", V: model.CallbackTypeSettingsDifficulty},
+		{K: "切换动图验证码", V: model.CallbackTypeSettingsAnimated},
+	},
+	{
+		{K: "完成", V: model.CallbackTypeSettingsClose},
+	},
+}
+
+func (ga GroupAdmin) getSettings(ctx context.Context, chatID int64) (*model.ChatSettings, bool) {
+	settings, err := ga.settings.GetSettings(ctx, chatID)
+	if err != nil {
+		log.Println("get settings failed: ", err)
+		return nil, false
+	}
+	return settings, true
+}
+
+func (ga GroupAdmin) onSettings(ctx context.Context, chatID int64, args string) {
+	settings, ok := ga.getSettings(ctx, chatID)
+	if !ok {
+		return
+	}
+	sub := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	switch sub[0] {
+	case "welcome":
+		var welcome string
+		if len(sub) > 1 {
+			welcome = strings.TrimSpace(sub[1])
+		}
+		if n := len(utf16.Encode([]rune(welcome))); n > model.MaxWelcomeMsgLen {
+			if _, err := ga.bot.SendMsg(chatID, fmt.Sprintf(model.WelcomeTooLongMsg, n, model.MaxWelcomeMsgLen)); err != nil {
+				log.Println("send welcome invalid failed: ", err)
+			}
+			return
+		}
+		settings.WelcomeMsg = welcome
+	case "words":
+		var words []string
+		if len(sub) > 1 {
+			var ok bool
+			if words, ok = ga.validWords(chatID, *settings, sub[1]); !ok {
+				return
+			}
+		}
+		settings.Words = words
+	}
+	if sub[0] == "welcome" || sub[0] == "words" {
+		if err := ga.settings.PutSettings(ctx, *settings); err != nil {
+			log.Println("put settings failed: ", err)
+			return
+		}
+	}
+	ga.sendSettings(chatID, *settings)
+}
+
+func (ga GroupAdmin) sendSettings(chatID int64, settings model.ChatSettings) {
+	_, err := ga.bot.SendMsgWithKeyboard(chatID, settingsText(settings), settingsKeyboard)
+	if err != nil {
+		log.Println("send settings failed: ", err)
+	}
+}
+
+// validWords 解析 text 中的自定义词库，开启拼音答题或词库无效时发送失败原因并返回 false
+func (ga GroupAdmin) validWords(chatID int64, settings model.ChatSettings, text string) ([]string, bool) {
+	if settings.PinyinAnswer {
+		if _, err := ga.bot.SendMsg(chatID, model.WordsPinyinConflictMsg); err != nil {
+			log.Println("send words invalid failed: ", err)
+		}
+		return nil, false
+	}
+	words, err := parseWords(text)
+	if err != nil {
+		if _, err := ga.bot.SendMsg(chatID, fmt.Sprintf(model.WordsInvalidMsg, err, model.ChoiceCount, model.MaxCustomWords)); err != nil {
+			log.Println("send words invalid failed: ", err)
+		}
+		return nil, false
+	}
+	return words, true
+}
+
+// onWordsFile 以 .txt 文件中每行一个的词语设置自定义词库，
+// 避免词库较大时命令超过 Telegram 单条消息 4096 字符的上限
+func (ga GroupAdmin) onWordsFile(ctx context.Context, chatID int64, fileID, fileName string, fileSize int) {
+	settings, ok := ga.getSettings(ctx, chatID)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(fileName), ".txt") || fileSize > model.MaxWordsFileSize {
+		if _, err := ga.bot.SendMsg(chatID, fmt.Sprintf(model.WordsFileInvalidMsg, model.MaxWordsFileSize/1024)); err != nil {
+			log.Println("send words invalid failed: ", err)
+		}
+		return
+	}
+	b, err := ga.bot.GetFile(fileID, model.MaxWordsFileSize)
+	if err != nil {
+		log.Println("get words file failed: ", err)
+		if _, err := ga.bot.SendMsg(chatID, fmt.Sprintf(model.WordsFileInvalidMsg, model.MaxWordsFileSize/1024)); err != nil {
+			log.Println("send words invalid failed: ", err)
+		}
+		return
+	}
+	// Windows 记事本保存的文件以 BOM 开头
+	words, ok := ga.validWords(chatID, *settings, strings.TrimPrefix(string(b), "\ufeff"))
+	if !ok {
+		return
+	}
+	settings.Words = words
+	if err := ga.settings.PutSettings(ctx, *settings); err != nil {
+		log.Println("put settings failed: ", err)
+		return
+	}
+	ga.sendSettings(chatID, *settings)
+}
+
+func (ga GroupAdmin) onSettingsCallback(ctx context.Context, chatID int64, msgID int, callbackID, data string) {
+	if data == model.CallbackTypeSettingsClose {
+		ga.bot.DeleteMsg(chatID, msgID)
+		ga.bot.AnswerCallback(callbackID, "设置已保存")
+		return
+	}
+	settings, ok := ga.getSettings(ctx, chatID)
+	if !ok {
+		ga.bot.AnswerCallback(callbackID, "读取设置失败")
+		return
+	}
+	switch data {
+	case model.CallbackTypeSettingsTimeout:
+		settings.Timeout = nextOption(model.TimeoutOptions, settings.Timeout)
+	case model.CallbackTypeSettingsBanDuration:
+		settings.BanDuration = nextOption(model.BanDurationOptions, settings.BanDuration)
+	case model.CallbackTypeSettingsCaptchaType:
+		settings.CaptchaType = nextCaptchaType(ga.captchaTypes, settings.CaptchaType)
+	case model.CallbackTypeSettingsDeleteJoin:
+		settings.DeleteJoinMsg = !settings.DeleteJoinMsg
+	case model.CallbackTypeSettingsMaxAttempts:
+		settings.MaxAttempts = nextOption(model.MaxAttemptsOptions, settings.MaxAttempts)
+	case model.CallbackTypeSettingsChoiceMode:
+		settings.ChoiceMode = !settings.ChoiceMode
+	case model.CallbackTypeSettingsFuzzyMatch:
+		settings.FuzzyMatch = !settings.FuzzyMatch
+	case model.CallbackTypeSettingsPinyin:
+		if !settings.PinyinAnswer && len(settings.Words) > 0 {
+			ga.bot.AnswerCallback(callbackID, "自定义词库的词语没有拼音，请先清空词库")
+			return
+		}
+		settings.PinyinAnswer = !settings.PinyinAnswer
+	case model.CallbackTypeSettingsDifficulty:
+		settings.Difficulty = nextOption(model.DifficultyOptions, settings.Difficulty)
+	case model.CallbackTypeSettingsAnimated:
+		settings.Animated = !settings.Animated
+	default:
+		return
+	}
+	if err := ga.settings.PutSettings(ctx, *settings); err != nil {
+		log.Println("put settings failed: ", err)
+		ga.bot.AnswerCallback(callbackID, "保存设置失败")
+		return
+	}
+	ga.bot.UpdateMsg(chatID, msgID, settingsText(*settings), settingsKeyboard)
+	ga.bot.AnswerCallback(callbackID, "设置已更新")
+}
+
+func settingsText(settings model.ChatSettings) string {
+	welcomeMsg := "默认"
+	if settings.WelcomeMsg != "" {
+		welcomeMsg = html.EscapeString(settings.WelcomeMsg)
+	}
+	return fmt.Sprintf(model.SettingsMsg,
+		settings.Timeout,
+		utils.FormatDuration(settings.BanDuration),
+		captchaTitle(settings.CaptchaType),
+		onOff(settings.DeleteJoinMsg),
+		maxAttemptsText(settings.MaxAttempts),
+		answerModeText(settings.ChoiceMode),
+		onOff(settings.FuzzyMatch),
+		onOff(settings.PinyinAnswer),
+		model.DifficultyNames[settings.Difficulty],
+		wordsText(settings.Words),
+		onOff(settings.Animated),
+		welcomeMsg,
+	)
+}
+
+func maxAttemptsText(maxAttempts int) string {
+	if maxAttempts <= 0 {
+		return "不限"
+	}
+	return fmt.Sprintf("%d 次", maxAttempts)
+}
+
+func answerModeText(choiceMode bool) string {
+	if choiceMode {
+		return "点击按钮（选错即移出）"
+	}
+	return "输入答案"
+}
+
+func wordsText(words []string) string {
+	if len(words) == 0 {
+		return "未设置"
+	}
+	return fmt.Sprintf("%d 个词", len(words))
+}
+
+// parseWords 解析以空白或逗号分隔的自定义词库，词库须包含 model.ChoiceCount 至
+// model.MaxCustomWords 个互不相同的四字中文词语，以便按钮答题模式凑齐选项
+func parseWords(args string) ([]string, error) {
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",，、;；", r)
+	})
+	var words []string
+	seen := map[string]bool{}
+	for _, w := range fields {
+		runes := []rune(w)
+		if len(runes) != 4 {
+			return nil, xerrors.Errorf("「%s」不是四字词语", html.EscapeString(w))
+		}
+		for _, r := range runes {
+			if !unicode.Is(unicode.Han, r) {
+				return nil, xerrors.Errorf("「%s」包含非中文字符", html.EscapeString(w))
+			}
+		}
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	if len(words) < model.ChoiceCount || len(words) > model.MaxCustomWords {
+		return nil, xerrors.Errorf("共 %d 个词", len(words))
+	}
+	return words, nil
+}
+
+func onOff(b bool) string {
+	if b {
+		return "开启"
+	}
+	return "关闭"
+}
+
+// nextOption 返回 options 中 current 的下一个值，current 不在其中时返回第一个值
+func nextOption(options []int, current int) int {
+	for i, v := range options {
+		if v == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// nextCaptchaType 返回 types 中 current 的下一个验证码类型，current 不在其中时返回第一个类型
+func nextCaptchaType(types []string, current string) string {
+	for i, v := range types {
+		if v == current {
+			return types[(i+1)%len(types)]
+		}
+	}
+	return types[0]
+}
+
+// captchaTitle 返回验证码类型的显示名称，类型未注册时直接显示名称
+func captchaTitle(captchaType string) string {
+	if p, ok := captcha.Lookup(captchaType); ok && p.Title != "" {
+		return p.Title
+	}
+	return captchaType
+}
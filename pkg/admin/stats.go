@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+)
+
+func (ga GroupAdmin) onStats(ctx context.Context, chatID int64, now time.Time) {
+	maxPeriod := model.StatsPeriods[len(model.StatsPeriods)-1]
+	stats, err := ga.stats.ListStats(ctx, chatID, model.StatsDay(now.AddDate(0, 0, 1-maxPeriod)))
+	if err != nil {
+		log.Println("list stats failed: ", err)
+		return
+	}
+	if _, err := ga.bot.SendMsg(chatID, statsText(stats, now)); err != nil {
+		log.Println("send stats failed: ", err)
+	}
+}
+
+// statsText 按 StatsPeriods 汇总截至 now 所在日期的统计，近 1 天即为当天
+func statsText(stats []model.DailyStats, now time.Time) string {
+	lines := make([]string, 0, len(model.StatsPeriods))
+	for _, period := range model.StatsPeriods {
+		since := model.StatsDay(now.AddDate(0, 0, 1-period))
+		sum := model.DailyStats{}
+		for _, v := range stats {
+			if v.Day >= since {
+				sum.Add(v)
+			}
+		}
+		lines = append(lines, fmt.Sprintf(model.StatsLineMsg, period,
+			sum.Challenged, sum.Passed, sum.TimedOut, sum.Kicked, sum.LockedOut,
+		))
+	}
+	return fmt.Sprintf(model.StatsMsg, strings.Join(lines, "\n"))
+}
@@ -9,13 +9,24 @@ import (
 
 type Interface interface {
 	SendMsg(chatID int64, msg string) (int, error)
+	SendMsgWithKeyboard(chatID int64, msg string, keyboard [][]model.KV) (int, error)
+	UpdateMsg(chatID int64, msgID int, msg string, keyboard [][]model.KV)
 	SetWebhook(addr string) error
 	DeleteMsg(chatID int64, msgID int)
 	SendImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error)
 	UpdateCaption(chatID int64, msgID int, caption string, keyboard [][]model.KV)
 	UpdatePhoto(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte)
+	// SendCachedImg 与 UpdateCachedPhoto 用于反复发送的相同图片，如捐赠二维码，
+	// 上传过的图片以 file_id 发送。验证码图片只发送一次，不经过缓存
+	SendCachedImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error)
+	UpdateCachedPhoto(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte)
+	// SendAnimation 与 UpdateAnimation 以动图形式发送或替换 GIF 验证码
+	SendAnimation(chatID int64, gif []byte, caption string, keyboard [][]model.KV) (int, error)
+	UpdateAnimation(chatID int64, msgID int, caption string, keyboard [][]model.KV, gif []byte)
 	AnswerCallback(callbackID, text string)
 	Kick(chatID int64, userID int, until time.Time)
 	IsAdmin(chatID int64, userID int) bool
 	HasLeft(chatID int64, userID int) bool
+	// GetFile 下载用户发送的文件，文件超过 maxSize 字节时返回错误
+	GetFile(fileID string, maxSize int) ([]byte, error)
 }
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/xerrors"
+)
+
+// fileIDCacheSize 为最多记录的 file_id 数量，超出时淘汰最久未使用的记录
+const fileIDCacheSize = 1000
+
+// fileKey 以媒体类型及文件内容的 sha256 标识已上传的文件，同一文件以不同类型发送时 file_id 不通用
+type fileKey struct {
+	mediaType string
+	sum       [sha256.Size]byte
+}
+
+func fileKeyOf(mediaType string, b []byte) fileKey {
+	return fileKey{mediaType: mediaType, sum: sha256.Sum256(b)}
+}
+
+type fileIDEntry struct {
+	key    fileKey
+	fileID string
+}
+
+// fileIDCache 记录上传文件后 Telegram 返回的 file_id，再次发送相同文件时无需重新上传
+type fileIDCache struct {
+	mu    sync.Mutex
+	size  int
+	ids   map[fileKey]*list.Element
+	order *list.List
+}
+
+func newFileIDCache(size int) *fileIDCache {
+	return &fileIDCache{
+		size:  size,
+		ids:   map[fileKey]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (c *fileIDCache) get(key fileKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.ids[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*fileIDEntry).fileID, true
+}
+
+// put 忽略空的 fileID
+func (c *fileIDCache) put(key fileKey, fileID string) {
+	if fileID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.ids[key]; ok {
+		e.Value.(*fileIDEntry).fileID = fileID
+		c.order.MoveToFront(e)
+		return
+	}
+	c.ids[key] = c.order.PushFront(&fileIDEntry{key: key, fileID: fileID})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.ids, oldest.Value.(*fileIDEntry).key)
+	}
+}
+
+func (c *fileIDCache) remove(key fileKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.ids[key]; ok {
+		c.order.Remove(e)
+		delete(c.ids, key)
+	}
+}
+
+// isFileIDRejected 判断请求是否因 file_id 无效被 Telegram 拒绝
+func isFileIDRejected(err error) bool {
+	var tgErr tgbotapi.Error
+	if !xerrors.As(err, &tgErr) {
+		return false
+	}
+	msg := strings.ToLower(tgErr.Message)
+	return strings.Contains(msg, "wrong file identifier") || strings.Contains(msg, "wrong remote file identifier")
+}
+
+// fileIDOf 返回消息中 mediaType 类型媒体的 file_id，图片取尺寸最大的一张
+func fileIDOf(mediaType string, msg *tgbotapi.Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case mediaType == "photo" && msg.Photo != nil && len(*msg.Photo) > 0:
+		photos := *msg.Photo
+		return photos[len(photos)-1].FileID
+	case mediaType == "animation" && msg.Animation != nil:
+		return msg.Animation.FileID
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTelegram 记录每次请求是否上传了文件及使用的 file_id
+type fakeTelegram struct {
+	uploads []string
+	fileIDs []string
+	// rejectFileID 非空时以该 file_id 发送的请求失败，失败原因为 rejectReason
+	rejectFileID string
+	rejectReason string
+}
+
+func (f *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	result := `{"ok": true, "result": {"message_id": 1, "photo": [{"file_id": "small"}, {"file_id": "large"}]}}`
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		f.uploads = append(f.uploads, method)
+	} else {
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		fileID := req.Form.Get("photo")
+		if media := req.Form.Get("media"); media != "" {
+			var v struct{ Media string }
+			if err := json.Unmarshal([]byte(media), &v); err != nil {
+				return nil, err
+			}
+			fileID = v.Media
+		}
+		f.fileIDs = append(f.fileIDs, method+":"+fileID)
+		if fileID == f.rejectFileID {
+			result = `{"ok": false, "description": "` + f.rejectReason + `"}`
+		}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(result)),
+		Request:    req,
+	}, nil
+}
+
+func TestFileIDCache(t *testing.T) {
+	newAPI := func(f *fakeTelegram) TGBotAPI {
+		return TGBotAPI{
+			bot:     &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: f}},
+			fileIDs: newFileIDCache(fileIDCacheSize),
+		}
+	}
+
+	t.Run("重复发送的图片使用 file_id", func(t *testing.T) {
+		f := &fakeTelegram{}
+		api := newAPI(f)
+		for i := 0; i < 2; i++ {
+			_, err := api.SendCachedImg(1, []byte("img"), "caption", nil)
+			assert.NoError(t, err)
+		}
+		api.UpdateCachedPhoto(1, 1, "caption", nil, []byte("img"))
+		_, err := api.SendCachedImg(1, []byte("other"), "caption", nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"sendPhoto", "sendPhoto"}, f.uploads)
+		assert.Equal(t, []string{"sendPhoto:large", "editMessageMedia:large"}, f.fileIDs)
+	})
+
+	t.Run("验证码图片不记录 file_id", func(t *testing.T) {
+		f := &fakeTelegram{}
+		api := newAPI(f)
+		for i := 0; i < 2; i++ {
+			_, err := api.SendImg(1, []byte("img"), "caption", nil)
+			assert.NoError(t, err)
+		}
+		api.UpdatePhoto(1, 1, "caption", nil, []byte("img"))
+
+		assert.Equal(t, []string{"sendPhoto", "sendPhoto", "editMessageMedia"}, f.uploads)
+		assert.Empty(t, f.fileIDs)
+	})
+
+	t.Run("file_id 失效时重新上传", func(t *testing.T) {
+		f := &fakeTelegram{rejectFileID: "large", rejectReason: "Bad Request: wrong file identifier/HTTP URL specified"}
+		api := newAPI(f)
+		api.UpdateCachedPhoto(1, 1, "caption", nil, []byte("img"))
+		_, err := api.SendCachedImg(1, []byte("img"), "caption", nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"editMessageMedia", "sendPhoto"}, f.uploads)
+		assert.Equal(t, []string{"sendPhoto:large"}, f.fileIDs)
+	})
+
+	t.Run("其他错误时不重新上传", func(t *testing.T) {
+		f := &fakeTelegram{rejectFileID: "large", rejectReason: "Too Many Requests: retry after 5"}
+		api := newAPI(f)
+		api.UpdateCachedPhoto(1, 1, "caption", nil, []byte("img"))
+		_, err := api.SendCachedImg(1, []byte("img"), "caption", nil)
+		assert.Error(t, err)
+		api.UpdateCachedPhoto(1, 1, "caption", nil, []byte("img"))
+
+		assert.Equal(t, []string{"editMessageMedia"}, f.uploads)
+		assert.Equal(t, []string{"sendPhoto:large", "editMessageMedia:large"}, f.fileIDs)
+	})
+
+	t.Run("淘汰最久未使用的记录", func(t *testing.T) {
+		c := newFileIDCache(2)
+		a, b, d := fileKeyOf("photo", []byte("a")), fileKeyOf("photo", []byte("b")), fileKeyOf("photo", []byte("d"))
+		c.put(a, "a")
+		c.put(b, "b")
+		_, ok := c.get(a)
+		assert.True(t, ok)
+		c.put(d, "d")
+		_, ok = c.get(b)
+		assert.False(t, ok)
+		id, ok := c.get(a)
+		assert.True(t, ok)
+		assert.Equal(t, "a", id)
+
+		_, ok = c.get(fileKeyOf("animation", []byte("a")))
+		assert.False(t, ok)
+	})
+}
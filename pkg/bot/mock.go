@@ -49,6 +49,33 @@ func (mr *MockInterfaceMockRecorder) SendMsg(chatID, msg interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockInterface)(nil).SendMsg), chatID, msg)
 }
 
+// SendMsgWithKeyboard mocks base method
+func (m *MockInterface) SendMsgWithKeyboard(chatID int64, msg string, keyboard [][]model.KV) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMsgWithKeyboard", chatID, msg, keyboard)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMsgWithKeyboard indicates an expected call of SendMsgWithKeyboard
+func (mr *MockInterfaceMockRecorder) SendMsgWithKeyboard(chatID, msg, keyboard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsgWithKeyboard", reflect.TypeOf((*MockInterface)(nil).SendMsgWithKeyboard), chatID, msg, keyboard)
+}
+
+// UpdateMsg mocks base method
+func (m *MockInterface) UpdateMsg(chatID int64, msgID int, msg string, keyboard [][]model.KV) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateMsg", chatID, msgID, msg, keyboard)
+}
+
+// UpdateMsg indicates an expected call of UpdateMsg
+func (mr *MockInterfaceMockRecorder) UpdateMsg(chatID, msgID, msg, keyboard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMsg", reflect.TypeOf((*MockInterface)(nil).UpdateMsg), chatID, msgID, msg, keyboard)
+}
+
 // SetWebhook mocks base method
 func (m *MockInterface) SetWebhook(addr string) error {
 	m.ctrl.T.Helper()
@@ -114,6 +141,60 @@ func (mr *MockInterfaceMockRecorder) UpdatePhoto(chatID, msgID, caption, keyboar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePhoto", reflect.TypeOf((*MockInterface)(nil).UpdatePhoto), chatID, msgID, caption, keyboard, img)
 }
 
+// SendCachedImg mocks base method
+func (m *MockInterface) SendCachedImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendCachedImg", chatID, img, caption, keyboard)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendCachedImg indicates an expected call of SendCachedImg
+func (mr *MockInterfaceMockRecorder) SendCachedImg(chatID, img, caption, keyboard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCachedImg", reflect.TypeOf((*MockInterface)(nil).SendCachedImg), chatID, img, caption, keyboard)
+}
+
+// UpdateCachedPhoto mocks base method
+func (m *MockInterface) UpdateCachedPhoto(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateCachedPhoto", chatID, msgID, caption, keyboard, img)
+}
+
+// UpdateCachedPhoto indicates an expected call of UpdateCachedPhoto
+func (mr *MockInterfaceMockRecorder) UpdateCachedPhoto(chatID, msgID, caption, keyboard, img interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCachedPhoto", reflect.TypeOf((*MockInterface)(nil).UpdateCachedPhoto), chatID, msgID, caption, keyboard, img)
+}
+
+// SendAnimation mocks base method
+func (m *MockInterface) SendAnimation(chatID int64, gif []byte, caption string, keyboard [][]model.KV) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendAnimation", chatID, gif, caption, keyboard)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendAnimation indicates an expected call of SendAnimation
+func (mr *MockInterfaceMockRecorder) SendAnimation(chatID, gif, caption, keyboard interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAnimation", reflect.TypeOf((*MockInterface)(nil).SendAnimation), chatID, gif, caption, keyboard)
+}
+
+// UpdateAnimation mocks base method
+func (m *MockInterface) UpdateAnimation(chatID int64, msgID int, caption string, keyboard [][]model.KV, gif []byte) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateAnimation", chatID, msgID, caption, keyboard, gif)
+}
+
+// UpdateAnimation indicates an expected call of UpdateAnimation
+func (mr *MockInterfaceMockRecorder) UpdateAnimation(chatID, msgID, caption, keyboard, gif interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAnimation", reflect.TypeOf((*MockInterface)(nil).UpdateAnimation), chatID, msgID, caption, keyboard, gif)
+}
+
 // AnswerCallback mocks base method
 func (m *MockInterface) AnswerCallback(callbackID, text string) {
 	m.ctrl.T.Helper()
@@ -165,3 +246,18 @@ func (mr *MockInterfaceMockRecorder) HasLeft(chatID, userID interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasLeft", reflect.TypeOf((*MockInterface)(nil).HasLeft), chatID, userID)
 }
+
+// GetFile mocks base method
+func (m *MockInterface) GetFile(fileID string, maxSize int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFile", fileID, maxSize)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFile indicates an expected call of GetFile
+func (mr *MockInterfaceMockRecorder) GetFile(fileID, maxSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockInterface)(nil).GetFile), fileID, maxSize)
+}
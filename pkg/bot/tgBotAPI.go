@@ -1,7 +1,11 @@
 package bot
 
 import (
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -12,14 +16,65 @@ import (
 )
 
 type TGBotAPI struct {
-	bot *tgbotapi.BotAPI
+	bot     *tgbotapi.BotAPI
+	fileIDs *fileIDCache
 }
 
-func (b TGBotAPI) SendImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error) {
-	captchaMsg := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
+// sendFile 发送 file 对应的消息，相同内容的文件已上传过时以 file_id 发送，file_id 被拒绝时重新上传
+func (b TGBotAPI) sendFile(key fileKey, file *tgbotapi.BaseFile, msg func() tgbotapi.Chattable) (int, error) {
+	if fileID, ok := b.fileIDs.get(key); ok {
+		file.FileID, file.UseExisting = fileID, true
+		resp, err := b.bot.Send(msg())
+		if err == nil {
+			return resp.MessageID, nil
+		}
+		// 其他错误时消息可能已经发出，重新上传会重复发送
+		if !isFileIDRejected(err) {
+			return -1, err
+		}
+		log.Printf("file_id 已失效，重新上传: %+v", err)
+		b.fileIDs.remove(key)
+		file.FileID, file.UseExisting = "", false
+	}
+	resp, err := b.bot.Send(msg())
+	if err != nil {
+		return -1, err
+	}
+	b.fileIDs.put(key, fileIDOf(key.mediaType, &resp))
+	return resp.MessageID, nil
+}
+
+func photoUpload(chatID int64, img []byte, caption string, keyboard [][]model.KV) tgbotapi.PhotoConfig {
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
 		Name:  strconv.FormatInt(time.Now().UnixNano(), 10),
 		Bytes: img,
 	})
+	photo.Caption = caption
+	photo.ParseMode = tgbotapi.ModeHTML
+	photo.ReplyMarkup = TransformKeyboard(keyboard)
+	return photo
+}
+
+func (b TGBotAPI) SendImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error) {
+	resp, err := b.bot.Send(photoUpload(chatID, img, caption, keyboard))
+	if err != nil {
+		return -1, err
+	}
+	return resp.MessageID, nil
+}
+
+func (b TGBotAPI) SendCachedImg(chatID int64, img []byte, caption string, keyboard [][]model.KV) (int, error) {
+	photo := photoUpload(chatID, img, caption, keyboard)
+	return b.sendFile(fileKeyOf("photo", img), &photo.BaseFile, func() tgbotapi.Chattable {
+		return photo
+	})
+}
+
+func (b TGBotAPI) SendAnimation(chatID int64, gif []byte, caption string, keyboard [][]model.KV) (int, error) {
+	captchaMsg := tgbotapi.NewAnimationUpload(chatID, tgbotapi.FileBytes{
+		Name:  strconv.FormatInt(time.Now().UnixNano(), 10) + ".gif",
+		Bytes: gif,
+	})
 	captchaMsg.Caption = caption
 	captchaMsg.ParseMode = tgbotapi.ModeHTML
 	captchaMsg.ReplyMarkup = TransformKeyboard(keyboard)
@@ -48,7 +103,8 @@ func NewAPI(botToken string) (Interface, error) {
 		return nil, xerrors.Errorf("初始化机器人失败: %w", err)
 	}
 	return &TGBotAPI{
-		bot: bot,
+		bot:     bot,
+		fileIDs: newFileIDCache(fileIDCacheSize),
 	}, nil
 }
 
@@ -62,6 +118,28 @@ func (b TGBotAPI) SendMsg(chatID int64, msg string) (int, error) {
 	return msgRst.MessageID, nil
 }
 
+func (b TGBotAPI) SendMsgWithKeyboard(chatID int64, msg string, keyboard [][]model.KV) (int, error) {
+	m := tgbotapi.NewMessage(chatID, msg)
+	m.ParseMode = tgbotapi.ModeHTML
+	m.ReplyMarkup = TransformKeyboard(keyboard)
+	msgRst, err := b.bot.Send(m)
+	if err != nil {
+		return -1, xerrors.Errorf("发送消息 %s 至 %d 失败: %w", msg, chatID, err)
+	}
+	return msgRst.MessageID, nil
+}
+
+func (b TGBotAPI) UpdateMsg(chatID int64, msgID int, msg string, keyboard [][]model.KV) {
+	editor := tgbotapi.NewEditMessageText(chatID, msgID, msg)
+	editor.ParseMode = tgbotapi.ModeHTML
+	markup := TransformKeyboard(keyboard)
+	editor.ReplyMarkup = &markup
+	_, err := b.bot.Send(editor)
+	if err != nil {
+		log.Printf("编辑消息: %d %d 失败: %+v", chatID, msgID, err)
+	}
+}
+
 func (b TGBotAPI) SetWebhook(addr string) error {
 	_, err := b.bot.SetWebhook(tgbotapi.NewWebhook(addr))
 	if err != nil {
@@ -119,6 +197,33 @@ func (b TGBotAPI) HasLeft(chatID int64, userID int) bool {
 	return false
 }
 
+func (b TGBotAPI) GetFile(fileID string, maxSize int) ([]byte, error) {
+	fileURL, err := b.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, xerrors.Errorf("获取文件 %s 失败: %w", fileID, err)
+	}
+	resp, err := b.bot.Client.Get(fileURL)
+	if err != nil {
+		// 下载地址包含机器人 token，不记录在错误中
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return nil, xerrors.Errorf("下载文件 %s 失败: %w", fileID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("下载文件 %s 失败: %s", fileID, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, xerrors.Errorf("下载文件 %s 失败: %w", fileID, err)
+	}
+	if len(data) > maxSize {
+		return nil, xerrors.Errorf("文件 %s 超过 %d 字节", fileID, maxSize)
+	}
+	return data, nil
+}
+
 func (b TGBotAPI) UpdateCaption(chatID int64, msgID int, caption string, keyboard [][]model.KV) {
 	editor := tgbotapi.NewEditMessageCaption(chatID, msgID, caption)
 	editor.ParseMode = tgbotapi.ModeHTML
@@ -130,8 +235,40 @@ func (b TGBotAPI) UpdateCaption(chatID int64, msgID int, caption string, keyboar
 	}
 }
 
+// updateMedia 将消息的媒体替换为 file，与 sendFile 一样优先使用已记录的 file_id
+func (b TGBotAPI) updateMedia(chatID int64, msgID int, mediaType, caption string, keyboard [][]model.KV, file tgbotapi.FileBytes) error {
+	key := fileKeyOf(mediaType, file.Bytes)
+	if fileID, ok := b.fileIDs.get(key); ok {
+		_, err := utils.UpdateMsgMedia(b.bot, chatID, msgID, mediaType, caption, tgbotapi.ModeHTML, TransformKeyboard(keyboard), fileID)
+		if err == nil {
+			return nil
+		}
+		if !isFileIDRejected(err) {
+			return err
+		}
+		log.Printf("file_id 已失效，重新上传: %+v", err)
+		b.fileIDs.remove(key)
+	}
+	msg, err := utils.UpdateMsgMedia(b.bot, chatID, msgID, mediaType, caption, tgbotapi.ModeHTML, TransformKeyboard(keyboard), file)
+	if err != nil {
+		return err
+	}
+	b.fileIDs.put(key, fileIDOf(mediaType, msg))
+	return nil
+}
+
 func (b TGBotAPI) UpdatePhoto(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte) {
-	_, err := utils.UpdateMsgPhoto(b.bot, chatID, msgID, caption, tgbotapi.ModeHTML, TransformKeyboard(keyboard), tgbotapi.FileBytes{
+	_, err := utils.UpdateMsgMedia(b.bot, chatID, msgID, "photo", caption, tgbotapi.ModeHTML, TransformKeyboard(keyboard), tgbotapi.FileBytes{
+		Name:  strconv.FormatInt(time.Now().UnixNano(), 10),
+		Bytes: img,
+	})
+	if err != nil {
+		log.Println("图片更新失败: ", err)
+	}
+}
+
+func (b TGBotAPI) UpdateCachedPhoto(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte) {
+	err := b.updateMedia(chatID, msgID, "photo", caption, keyboard, tgbotapi.FileBytes{
 		Name:  strconv.FormatInt(time.Now().UnixNano(), 10),
 		Bytes: img,
 	})
@@ -140,6 +277,16 @@ func (b TGBotAPI) UpdatePhoto(chatID int64, msgID int, caption string, keyboard
 	}
 }
 
+func (b TGBotAPI) UpdateAnimation(chatID int64, msgID int, caption string, keyboard [][]model.KV, gif []byte) {
+	_, err := utils.UpdateMsgMedia(b.bot, chatID, msgID, "animation", caption, tgbotapi.ModeHTML, TransformKeyboard(keyboard), tgbotapi.FileBytes{
+		Name:  strconv.FormatInt(time.Now().UnixNano(), 10) + ".gif",
+		Bytes: gif,
+	})
+	if err != nil {
+		log.Println("动图更新失败: ", err)
+	}
+}
+
 func (b TGBotAPI) AnswerCallback(callbackID, text string) {
 	_, err := b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackID, text))
 	if err != nil {
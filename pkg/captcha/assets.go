@@ -0,0 +1,13 @@
+package captcha
+
+import _ "embed"
+
+// defaultIdioms 为内置的成语词典，来源及许可见 data/README.md
+//
+//go:embed data/idiom.json
+var defaultIdioms []byte
+
+// defaultFont 为内置的文泉驿微米黑字体，同时包含中文及拉丁字母字形
+//
+//go:embed data/wqy-microhei.ttf
+var defaultFont []byte
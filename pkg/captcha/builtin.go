@@ -0,0 +1,64 @@
+package captcha
+
+import "github.com/jqs7/drei/pkg/model"
+
+// IdiomConfig 为成语验证码的配置，路径为空时使用内置词典及字体
+type IdiomConfig struct {
+	IdiomPath     string        `json:"idiomPath"`
+	FontPath      string        `json:"fontPath"`
+	MaxDifficulty int           `json:"maxDifficulty"`
+	Render        RenderOptions `json:"render"`
+}
+
+// MathConfig 为算术验证码的配置
+type MathConfig struct {
+	FontPath string        `json:"fontPath"`
+	Render   RenderOptions `json:"render"`
+}
+
+// TextConfig 为字母数字验证码的配置
+type TextConfig struct {
+	FontPath string        `json:"fontPath"`
+	Length   int           `json:"length"`
+	Charset  string        `json:"charset"`
+	Render   RenderOptions `json:"render"`
+}
+
+func init() {
+	Register(Plugin{
+		Name:  model.CaptchaTypeIdiom,
+		Title: model.CaptchaTypeNames[model.CaptchaTypeIdiom],
+		Hint:  model.CaptchaHints[model.CaptchaTypeIdiom],
+		NewConfig: func() interface{} {
+			return &IdiomConfig{MaxDifficulty: model.IdiomDifficultyHard}
+		},
+		Factory: func(config interface{}) (Interface, error) {
+			c := config.(*IdiomConfig)
+			return NewRandIdiomCaptcha(c.IdiomPath, c.FontPath, c.MaxDifficulty, c.Render, nil)
+		},
+	})
+	Register(Plugin{
+		Name:  model.CaptchaTypeMath,
+		Title: model.CaptchaTypeNames[model.CaptchaTypeMath],
+		Hint:  model.CaptchaHints[model.CaptchaTypeMath],
+		NewConfig: func() interface{} {
+			return &MathConfig{}
+		},
+		Factory: func(config interface{}) (Interface, error) {
+			c := config.(*MathConfig)
+			return NewRandMathCaptcha(c.FontPath, c.Render, nil)
+		},
+	})
+	Register(Plugin{
+		Name:  model.CaptchaTypeText,
+		Title: model.CaptchaTypeNames[model.CaptchaTypeText],
+		Hint:  model.CaptchaHints[model.CaptchaTypeText],
+		NewConfig: func() interface{} {
+			return &TextConfig{Length: DefaultTextLength, Charset: DefaultTextCharset}
+		},
+		Factory: func(config interface{}) (Interface, error) {
+			c := config.(*TextConfig)
+			return NewRandTextCaptcha(c.FontPath, c.Length, c.Charset, c.Render, nil)
+		},
+	})
+}
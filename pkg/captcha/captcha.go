@@ -4,6 +4,25 @@ import "github.com/jqs7/drei/pkg/model"
 
 //go:generate go run github.com/golang/mock/mockgen -source=captcha.go -package=captcha -destination=captcha_mock.go Interface
 type Interface interface {
-	GenRandImg() (model.Answer, []byte)
-	VerifyAnswer(answer, request model.Answer) bool
+	// GenRandImg 返回的答案应记录其 Token，Token 须自包含，不依赖词典等可变数据
+	GenRandImg(opts Options) (model.Answer, []byte)
+	// VerifyAnswer 在 answer.Token 为空时返回 false
+	VerifyAnswer(answer, request model.Answer, opts Options) bool
+	// GenChoices 返回包含正确答案在内的 n 个互不相同且顺序随机的选项，
+	// 以选项作为 request.String 并关闭 Fuzzy 及 Pinyin 后调用 VerifyAnswer 即可校验
+	GenChoices(answer model.Answer, n int, opts Options) []string
+}
+
+// Options 为群组级别的出题及答案校验选项，不适用的验证码类型会忽略对应选项
+type Options struct {
+	// Fuzzy 允许答案中有一个字错误
+	Fuzzy bool
+	// Pinyin 允许以不带声调的拼音回答成语验证码
+	Pinyin bool
+	// Difficulty 为成语验证码出题的最高难度，0 表示不限
+	Difficulty int
+	// Words 为群组自定义词库，非空时成语验证码只从中出题
+	Words []string
+	// Animated 时生成 GIF 动图，否则生成 PNG 图片
+	Animated bool
 }
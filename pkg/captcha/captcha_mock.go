@@ -34,30 +34,44 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 }
 
 // GenRandImg mocks base method
-func (m *MockInterface) GenRandImg() (model.Answer, []byte) {
+func (m *MockInterface) GenRandImg(opts Options) (model.Answer, []byte) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenRandImg")
+	ret := m.ctrl.Call(m, "GenRandImg", opts)
 	ret0, _ := ret[0].(model.Answer)
 	ret1, _ := ret[1].([]byte)
 	return ret0, ret1
 }
 
 // GenRandImg indicates an expected call of GenRandImg
-func (mr *MockInterfaceMockRecorder) GenRandImg() *gomock.Call {
+func (mr *MockInterfaceMockRecorder) GenRandImg(opts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenRandImg", reflect.TypeOf((*MockInterface)(nil).GenRandImg))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenRandImg", reflect.TypeOf((*MockInterface)(nil).GenRandImg), opts)
 }
 
 // VerifyAnswer mocks base method
-func (m *MockInterface) VerifyAnswer(answer, request model.Answer) bool {
+func (m *MockInterface) VerifyAnswer(answer, request model.Answer, opts Options) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VerifyAnswer", answer, request)
+	ret := m.ctrl.Call(m, "VerifyAnswer", answer, request, opts)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // VerifyAnswer indicates an expected call of VerifyAnswer
-func (mr *MockInterfaceMockRecorder) VerifyAnswer(answer, request interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) VerifyAnswer(answer, request, opts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAnswer", reflect.TypeOf((*MockInterface)(nil).VerifyAnswer), answer, request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAnswer", reflect.TypeOf((*MockInterface)(nil).VerifyAnswer), answer, request, opts)
+}
+
+// GenChoices mocks base method
+func (m *MockInterface) GenChoices(answer model.Answer, n int, opts Options) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenChoices", answer, n, opts)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GenChoices indicates an expected call of GenChoices
+func (mr *MockInterfaceMockRecorder) GenChoices(answer, n, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenChoices", reflect.TypeOf((*MockInterface)(nil).GenChoices), answer, n, opts)
 }
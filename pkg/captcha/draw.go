@@ -0,0 +1,162 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+
+	"github.com/hanguofeng/freetype-go-mirror/freetype"
+	"github.com/hanguofeng/freetype-go-mirror/freetype/truetype"
+)
+
+// 以下绘制及干扰逻辑移植自 github.com/hanguofeng/gocaptcha（MIT 许可，Copyright 2013 hanguofeng），
+// gocaptcha 使用以时间为种子的全局随机源，此处改为由调用方传入，相同的随机源绘制出相同的图片
+
+// paletteSize 为调色板中除透明色外的颜色数
+const paletteSize = 20
+
+// canvas 为绘制中的验证码图片
+type canvas struct {
+	*image.Paletted
+	rand *rand.Rand
+}
+
+// newCanvas 创建以随机主色及其不同亮度为调色板的空白图片
+func newCanvas(width, height int, rd *rand.Rand) canvas {
+	c := canvas{rand: rd}
+	c.Paletted = image.NewPaletted(image.Rect(0, 0, width, height), c.randomPalette())
+	return c
+}
+
+// rnd 返回 [from, to] 内的随机整数
+func (c canvas) rnd(from, to int) int {
+	return c.rand.Intn(to+1-from) + from
+}
+
+// rndf 返回 [from, to) 内的随机浮点数
+func (c canvas) rndf(from, to float64) float64 {
+	return (to-from)*c.rand.Float64() + from
+}
+
+func (c canvas) randomPalette() color.Palette {
+	p := make(color.Palette, paletteSize+1)
+	p[0] = color.RGBA{0xff, 0xff, 0xff, 0x00}
+	prim := color.RGBA{uint8(c.rnd(0, 255)), uint8(c.rnd(0, 255)), uint8(c.rnd(0, 255)), 0xff}
+	p[1] = prim
+	for i := 2; i <= paletteSize; i++ {
+		p[i] = c.randomBrightness(prim, 255)
+	}
+	return p
+}
+
+func (c canvas) randomBrightness(prim color.RGBA, max uint8) color.RGBA {
+	minc := min3(prim.R, prim.G, prim.B)
+	maxc := max3(prim.R, prim.G, prim.B)
+	if maxc > max {
+		return prim
+	}
+	n := c.rnd(0, int(max-maxc)) - int(minc)
+	return color.RGBA{uint8(int(prim.R) + n), uint8(int(prim.G) + n), uint8(int(prim.B) + n), prim.A}
+}
+
+func min3(x, y, z uint8) uint8 {
+	m := x
+	if y < m {
+		m = y
+	}
+	if z < m {
+		m = z
+	}
+	return m
+}
+
+func max3(x, y, z uint8) uint8 {
+	m := x
+	if y > m {
+		m = y
+	}
+	if z > m {
+		m = z
+	}
+	return m
+}
+
+// drawText 以白色背景绘制黑色文字，每个字符占 fontSize 像素宽并随机选用 fonts 中的字体
+func (c canvas) drawText(fonts []*truetype.Font, fontSize float64, text string) {
+	draw.Draw(c, c.Bounds(), image.White, image.Point{}, draw.Src)
+	ctx := freetype.NewContext()
+	ctx.SetFontSize(fontSize)
+	ctx.SetClip(c.Bounds())
+	ctx.SetDst(c)
+	ctx.SetSrc(image.Black)
+	step := int(ctx.PointToFix32(fontSize) >> 8)
+	for i, s := range []rune(text) {
+		ctx.SetFont(fonts[c.rand.Intn(len(fonts))])
+		// 字体缺少该字形时留空，与 gocaptcha 一致
+		_, _ = ctx.DrawString(string(s), freetype.Pt(step*i, step))
+	}
+}
+
+func (c canvas) drawHorizLine(fromX, toX, y int, colorIdx uint8) {
+	for x := fromX; x <= toX; x++ {
+		c.SetColorIndex(x, y, colorIdx)
+	}
+}
+
+func (c canvas) drawCircle(x, y, radius int, colorIdx uint8) {
+	f := 1 - radius
+	dfx := 1
+	dfy := -2 * radius
+	xo := 0
+	yo := radius
+
+	c.SetColorIndex(x, y+radius, colorIdx)
+	c.SetColorIndex(x, y-radius, colorIdx)
+	c.drawHorizLine(x-radius, x+radius, y, colorIdx)
+
+	for xo < yo {
+		if f >= 0 {
+			yo--
+			dfy += 2
+			f += dfy
+		}
+		xo++
+		dfx += 2
+		f += dfx
+		c.drawHorizLine(x-xo, x+xo, y+yo, colorIdx)
+		c.drawHorizLine(x-xo, x+xo, y-yo, colorIdx)
+		c.drawHorizLine(x-yo, x+yo, y+xo, colorIdx)
+		c.drawHorizLine(x-yo, x+yo, y-xo, colorIdx)
+	}
+}
+
+// applyFilter 按滤镜参数添加干扰
+func (c canvas) applyFilter(f FilterOptions) {
+	maxX, maxY := c.Bounds().Max.X, c.Bounds().Max.Y
+	switch f.Name {
+	case FilterNoiseLine:
+		for i := 0; i < f.Num; i++ {
+			x := c.rnd(0, maxX)
+			c.drawHorizLine(int(float32(x)/1.5), x, c.rnd(0, maxY), uint8(c.rnd(1, paletteSize)))
+		}
+	case FilterNoisePoint:
+		for i := 0; i < f.Num; i++ {
+			c.drawCircle(c.rnd(0, maxX), c.rnd(0, maxY), c.rnd(0, 2), uint8(c.rnd(1, paletteSize)))
+		}
+	case FilterStrike:
+		// 以正弦曲线绘制 f.Num 条相邻的删除线
+		y := c.rnd(maxY/2, maxY-maxY/2)
+		amplitude := c.rndf(10, 15)
+		period := c.rndf(80, 100)
+		dx := 2.0 * math.Pi / period
+		for x := 0; x < maxX; x++ {
+			xo := amplitude * math.Cos(float64(y)*dx)
+			yo := amplitude * math.Sin(float64(x)*dx)
+			for yn := 0; yn < f.Num; yn++ {
+				c.drawCircle(x+int(xo), y+int(yo)+(yn*(f.Num+1)), c.rnd(0, 2)/2, 1)
+			}
+		}
+	}
+}
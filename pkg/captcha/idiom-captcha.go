@@ -1,88 +1,168 @@
 package captcha
 
 import (
-	"bytes"
 	"encoding/json"
-	"image/png"
-	"log"
+	"io/ioutil"
 	"math/rand"
-	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/hanguofeng/gocaptcha"
 	"github.com/jqs7/drei/pkg/model"
 	"golang.org/x/xerrors"
 )
 
 type RandIdiomCaptcha struct {
-	idioms            []model.Idiom
-	captchaImgCfg     *gocaptcha.ImageConfig
-	captchaImgFilters *gocaptcha.ImageFilterManager
+	idioms []model.Idiom
+	// byWord 用于根据答案令牌查找成语的拼音
+	byWord map[string]model.Idiom
+	// pools 为各难度可出题的成语下标，pools[d] 包含难度不高于 d 的成语；
+	// pinyinPools 与之相同但只包含有拼音的成语，供开启拼音答题的群组出题
+	pools       map[int][]int
+	pinyinPools map[int][]int
+	renderer    *renderer
+	rand        *lockedRand
 }
 
-func NewRandIdiomCaptcha(idiomPath, fontPath string) (Interface, error) {
-	f, err := os.Open(idiomPath)
+// NewRandIdiomCaptcha 只加载难度不高于 maxDifficulty 的四字成语，未标注难度的成语视为困难，
+// maxDifficulty 不在有效范围内时加载全部成语。idiomPath 及 fontPath 为空时使用内置词典及字体，
+// src 为出题、绘制图片及生成选项使用的随机源，为 nil 时使用以 crypto/rand 为种子的随机源
+func NewRandIdiomCaptcha(idiomPath, fontPath string, maxDifficulty int, renderOpts RenderOptions, src rand.Source) (Interface, error) {
+	rd := newLockedRand(src)
+	renderer, err := newRenderer(renderOpts, fontPath, cjkFonts, 80, rd)
 	if err != nil {
-		return nil, xerrors.Errorf("读取 %s 文件失败: %w", idiomPath, err)
+		return nil, err
+	}
+	data := defaultIdioms
+	if idiomPath != "" {
+		if data, err = ioutil.ReadFile(idiomPath); err != nil {
+			return nil, xerrors.Errorf("读取 %s 文件失败: %w", idiomPath, err)
+		}
 	}
 	var idioms []model.Idiom
-	if err := json.NewDecoder(f).Decode(&idioms); err != nil {
+	if err := json.Unmarshal(data, &idioms); err != nil {
 		return nil, xerrors.Errorf("解码 idiom 文件失败: %w", err)
 	}
+	if maxDifficulty < model.IdiomDifficultyEasy || maxDifficulty > model.IdiomDifficultyHard {
+		maxDifficulty = model.IdiomDifficultyHard
+	}
 	tmp := idioms[:0]
 	for _, p := range idioms {
-		if len([]rune(p.Word)) == 4 {
+		if len([]rune(p.Word)) == 4 && difficultyOf(p) <= maxDifficulty {
 			tmp = append(tmp, p)
 		}
 	}
 	idioms = tmp
-
-	filterConfig := new(gocaptcha.FilterConfig)
-	filterConfig.Init()
-	filterConfig.Filters = []string{
-		gocaptcha.IMAGE_FILTER_NOISE_LINE,
-		gocaptcha.IMAGE_FILTER_NOISE_POINT,
-		gocaptcha.IMAGE_FILTER_STRIKE,
-	}
-	for _, v := range filterConfig.Filters {
-		filterConfigGroup := new(gocaptcha.FilterConfigGroup)
-		filterConfigGroup.Init()
-		filterConfigGroup.SetItem("Num", "180")
-		filterConfig.SetGroup(v, filterConfigGroup)
+	if len(idioms) == 0 {
+		return nil, xerrors.Errorf("%s 中没有难度不高于 %d 的四字成语", idiomPath, maxDifficulty)
 	}
 
+	byWord := make(map[string]model.Idiom, len(idioms))
+	pools, pinyinPools := map[int][]int{}, map[int][]int{}
+	for i, p := range idioms {
+		byWord[p.Word] = p
+		for d := difficultyOf(p); d <= model.IdiomDifficultyHard; d++ {
+			pools[d] = append(pools[d], i)
+			if p.Pinyin != "" {
+				pinyinPools[d] = append(pinyinPools[d], i)
+			}
+		}
+	}
 	return &RandIdiomCaptcha{
-		idioms: idioms,
-		captchaImgCfg: &gocaptcha.ImageConfig{
-			Width:    320,
-			Height:   100,
-			FontSize: 80,
-			FontFiles: []string{
-				filepath.Join(fontPath, "STFANGSO.ttf"),
-				filepath.Join(fontPath, "STHEITI.ttf"),
-				filepath.Join(fontPath, "STXIHEI.ttf"),
-			},
-		},
-		captchaImgFilters: gocaptcha.CreateImageFilterManagerByConfig(filterConfig),
+		idioms:      idioms,
+		byWord:      byWord,
+		pools:       pools,
+		pinyinPools: pinyinPools,
+		renderer:    renderer,
+		rand:        rd,
 	}, nil
 }
 
-func (r RandIdiomCaptcha) GenRandImg() (model.Answer, []byte) {
-	rIdx := rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(r.idioms))
+func difficultyOf(idiom model.Idiom) int {
+	if idiom.Difficulty < model.IdiomDifficultyEasy || idiom.Difficulty > model.IdiomDifficultyHard {
+		return model.IdiomDifficultyHard
+	}
+	return idiom.Difficulty
+}
+
+// candidates 返回可出题的词数及第 i 个词。设置了自定义词库时从词库出题，
+// 否则从不高于群组难度的成语中出题，该难度没有成语时不限难度；
+// 开启拼音答题时只从有拼音的成语中出题，词典中没有任何成语有拼音时不限
+func (r RandIdiomCaptcha) candidates(opts Options) (int, func(i int) string) {
+	if len(opts.Words) > 0 {
+		return len(opts.Words), func(i int) string {
+			return opts.Words[i]
+		}
+	}
+	pools := r.pools
+	if opts.Pinyin && len(r.pinyinPools) > 0 {
+		pools = r.pinyinPools
+	}
+	pool := pools[opts.Difficulty]
+	if len(pool) == 0 {
+		pool = pools[model.IdiomDifficultyHard]
+	}
+	return len(pool), func(i int) string {
+		return r.idioms[pool[i]].Word
+	}
+}
+
+// word 返回答案对应的成语。答案令牌即成语本身，因此更换词典或词库不影响已发出的验证码；
+// 令牌为空时无法确定答案，返回 false
+func (r RandIdiomCaptcha) word(answer model.Answer) (model.Idiom, bool) {
+	if answer.Token == "" {
+		return model.Idiom{}, false
+	}
+	if idiom, ok := r.byWord[answer.Token]; ok {
+		return idiom, true
+	}
+	return model.Idiom{Word: answer.Token}, true
+}
+
+func (r RandIdiomCaptcha) drawScope(opts Options) Options {
+	return Options{Difficulty: opts.Difficulty, Pinyin: opts.Pinyin}
+}
+
+func (r RandIdiomCaptcha) GenRandImg(opts Options) (model.Answer, []byte) {
+	size, wordOf := r.candidates(opts)
+	word := wordOf(r.rand.Intn(size))
+
+	return model.Answer{Token: word}, r.renderer.render(word, opts)
+}
 
-	cImg := gocaptcha.CreateCImage(r.captchaImgCfg)
-	cImg.DrawString(r.idioms[rIdx].Word)
-	for _, f := range r.captchaImgFilters.GetFilters() {
-		f.Proc(cImg)
+func (r RandIdiomCaptcha) GenChoices(answer model.Answer, n int, opts Options) []string {
+	idiom, ok := r.word(answer)
+	if !ok {
+		return nil
 	}
-	captchaBuffer := bytes.NewBuffer([]byte{})
-	if err := png.Encode(captchaBuffer, cImg); err != nil {
-		log.Fatalln("encode png img failed: ", err)
+	size, wordOf := r.candidates(opts)
+	picked := map[string]bool{idiom.Word: true}
+	choices := []string{idiom.Word}
+	// 词库过小时可能凑不齐 n 个选项，尝试有限次数后放弃
+	for i := 0; len(choices) < n && i < n*100; i++ {
+		candidate := wordOf(r.rand.Intn(size))
+		if picked[candidate] {
+			continue
+		}
+		picked[candidate] = true
+		choices = append(choices, candidate)
 	}
-	return model.Answer{Number: rIdx}, captchaBuffer.Bytes()
+	r.rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	return choices
 }
 
-func (r RandIdiomCaptcha) VerifyAnswer(answer, request model.Answer) bool {
-	return r.idioms[answer.Number].Word == request.String
+// VerifyAnswer 忽略空白、标点及全半角差异，并接受繁体字答案，opts.Fuzzy 时允许错一个字，
+// opts.Pinyin 时还接受不带声调的拼音
+func (r RandIdiomCaptcha) VerifyAnswer(answer, request model.Answer, opts Options) bool {
+	idiom, ok := r.word(answer)
+	if !ok {
+		return false
+	}
+	if opts.Pinyin && matchPinyin(idiom.Pinyin, request.String) {
+		return true
+	}
+	maxTypos := 0
+	if opts.Fuzzy {
+		maxTypos = 1
+	}
+	return matchWord(idiom.Word, request.String, maxTypos)
 }
@@ -0,0 +1,161 @@
+package captcha
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandIdiomCaptcha(t *testing.T) {
+	idioms := []model.Idiom{{Word: "画蛇添足", Pinyin: "huà shé tiān zú"}, {Word: "绿树成荫", Pinyin: "lǜ shù chéng yīn"}}
+	c := RandIdiomCaptcha{
+		idioms: idioms,
+		byWord: map[string]model.Idiom{idioms[0].Word: idioms[0], idioms[1].Word: idioms[1]},
+		rand:   newLockedRand(rand.NewSource(1)),
+	}
+	answer := model.Answer{Token: "画蛇添足"}
+
+	t.Run("标准化答案", func(t *testing.T) {
+		for _, v := range []string{"画蛇添足", " 画蛇添足 ", "画 蛇 添 足", "画蛇添足。", "“画蛇添足”！", "畫蛇添足"} {
+			assert.True(t, c.VerifyAnswer(answer, model.Answer{String: v}, Options{}), v)
+		}
+		for _, v := range []string{"画蛇添", "画龙添足", "画蛇添足足", ""} {
+			assert.False(t, c.VerifyAnswer(answer, model.Answer{String: v}, Options{}), v)
+		}
+	})
+
+	t.Run("容错匹配", func(t *testing.T) {
+		opts := Options{Fuzzy: true}
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "画龙添足"}, opts))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "畫龍添足"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "画龙点足"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "画蛇添"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "画蛇添足了"}, opts))
+	})
+
+	t.Run("拼音答题", func(t *testing.T) {
+		opts := Options{Pinyin: true}
+		for _, v := range []string{"hua she tian zu", "huashetianzu", "Hua She Tian Zu", "huà shé tiān zú", "hua4 she2 tian1 zu2"} {
+			assert.True(t, c.VerifyAnswer(answer, model.Answer{String: v}, opts), v)
+			assert.False(t, c.VerifyAnswer(answer, model.Answer{String: v}, Options{}), v)
+		}
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "画蛇添足"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "hua she tian"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "hua long tian zu"}, opts))
+		for _, v := range []string{"lv shu cheng yin", "lu shu cheng yin", "lü shù chéng yīn"} {
+			assert.True(t, c.VerifyAnswer(model.Answer{Token: "绿树成荫"}, model.Answer{String: v}, opts), v)
+		}
+	})
+
+	t.Run("答案令牌为空", func(t *testing.T) {
+		assert.False(t, c.VerifyAnswer(model.Answer{}, model.Answer{String: "画蛇添足"}, Options{}))
+		assert.Nil(t, c.GenChoices(model.Answer{}, 4, Options{}))
+	})
+
+	t.Run("按难度加载及出题", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "idiom-*.json")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(`[
+			{"word": "一心一意", "difficulty": 1},
+			{"word": "画蛇添足", "difficulty": 2},
+			{"word": "魑魅魍魉"},
+			{"word": "一言既出，驷马难追", "difficulty": 1}
+		]`)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		words := func(c Interface, opts Options) []string {
+			r := c.(*RandIdiomCaptcha)
+			size, wordOf := r.candidates(opts)
+			var words []string
+			for i := 0; i < size; i++ {
+				words = append(words, wordOf(i))
+			}
+			return words
+		}
+
+		all, err := NewRandIdiomCaptcha(f.Name(), "", 0, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"一心一意", "画蛇添足", "魑魅魍魉"}, words(all, Options{}))
+		assert.Equal(t, []string{"一心一意"}, words(all, Options{Difficulty: model.IdiomDifficultyEasy}))
+		assert.Equal(t, []string{"一心一意", "画蛇添足"}, words(all, Options{Difficulty: model.IdiomDifficultyMedium}))
+
+		medium, err := NewRandIdiomCaptcha(f.Name(), "", model.IdiomDifficultyMedium, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"一心一意", "画蛇添足"}, words(medium, Options{}))
+
+		_, err = NewRandIdiomCaptcha(f.Name()+".missing", "", 0, RenderOptions{}, nil)
+		assert.Error(t, err)
+
+		embedded, err := NewRandIdiomCaptcha("", "", 0, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		for _, d := range []int{model.IdiomDifficultyEasy, model.IdiomDifficultyMedium, model.IdiomDifficultyHard} {
+			assert.NotEmpty(t, words(embedded, Options{Difficulty: d}), d)
+			assert.NotEmpty(t, words(embedded, Options{Difficulty: d, Pinyin: true}), d)
+		}
+	})
+
+	t.Run("开启拼音答题时只从有拼音的成语出题", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "idiom-*.json")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(`[
+			{"word": "一心一意", "difficulty": 1},
+			{"word": "画蛇添足", "pinyin": "huà shé tiān zú", "difficulty": 2},
+			{"word": "魑魅魍魉"}
+		]`)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		c, err := NewRandIdiomCaptcha(f.Name(), "", 0, RenderOptions{}, rand.NewSource(1))
+		assert.NoError(t, err)
+		r := c.(*RandIdiomCaptcha)
+		for _, d := range []int{0, model.IdiomDifficultyEasy, model.IdiomDifficultyHard} {
+			size, wordOf := r.candidates(Options{Difficulty: d, Pinyin: true})
+			assert.Equal(t, 1, size, d)
+			assert.Equal(t, "画蛇添足", wordOf(0), d)
+		}
+		size, _ := r.candidates(Options{})
+		assert.Equal(t, 3, size)
+	})
+
+	t.Run("自定义词库", func(t *testing.T) {
+		opts := Options{Words: []string{"守株待兔", "对牛弹琴", "掩耳盗铃", "刻舟求剑"}}
+		answer := model.Answer{Token: "对牛弹琴"}
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "对牛弹琴"}, opts))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "画蛇添足"}, opts))
+		// 词库被清空后已发出的验证码依然有效
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "对牛弹琴"}, Options{}))
+
+		choices := c.GenChoices(answer, 4, opts)
+		assert.ElementsMatch(t, opts.Words, choices)
+	})
+
+	t.Run("注入随机源", func(t *testing.T) {
+		opts := Options{Difficulty: model.IdiomDifficultyEasy}
+		gen := func() ([]string, []string) {
+			c, err := NewRandIdiomCaptcha("", "", 0, RenderOptions{Filters: []FilterOptions{}}, rand.NewSource(42))
+			assert.NoError(t, err)
+			var tokens []string
+			for i := 0; i < 5; i++ {
+				answer, _ := c.GenRandImg(opts)
+				tokens = append(tokens, answer.Token)
+			}
+			return tokens, c.GenChoices(model.Answer{Token: tokens[0]}, 4, opts)
+		}
+		tokens, choices := gen()
+		all, err := NewRandIdiomCaptcha("", "", 0, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		size, wordOf := all.(*RandIdiomCaptcha).candidates(opts)
+		assert.Equal(t, wordOf(rand.New(rand.NewSource(42)).Intn(size)), tokens[0])
+		// 相同的随机源得到相同的题目及选项
+		sameTokens, sameChoices := gen()
+		assert.Equal(t, tokens, sameTokens)
+		assert.Equal(t, choices, sameChoices)
+	})
+}
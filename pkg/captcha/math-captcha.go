@@ -0,0 +1,93 @@
+package captcha
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// RandMathCaptcha 生成两个数加、减、乘的算式，用户回复计算结果即可通过验证，不需要输入中文
+type RandMathCaptcha struct {
+	renderer *renderer
+	rand     *lockedRand
+}
+
+// NewRandMathCaptcha 的 fontPath 为空时使用内置字体，
+// src 为出题、绘制图片及生成选项使用的随机源，为 nil 时使用以 crypto/rand 为种子的随机源
+func NewRandMathCaptcha(fontPath string, renderOpts RenderOptions, src rand.Source) (Interface, error) {
+	// 算式最长为 "20×20=" 共 6 个字符
+	rd := newLockedRand(src)
+	renderer, err := newRenderer(renderOpts, fontPath, cjkFonts, 52, rd)
+	if err != nil {
+		return nil, err
+	}
+	return &RandMathCaptcha{renderer: renderer, rand: rd}, nil
+}
+
+// randExpr 返回随机算式及其结果，减法保证结果非负
+func randExpr(r intn) (string, int) {
+	a, b := r.Intn(20)+1, r.Intn(20)+1
+	switch r.Intn(3) {
+	case 0:
+		return fmt.Sprintf("%d+%d=", a, b), a + b
+	case 1:
+		if a < b {
+			a, b = b, a
+		}
+		return fmt.Sprintf("%d-%d=", a, b), a - b
+	default:
+		a, b = a%10+1, b%10+1
+		return fmt.Sprintf("%d×%d=", a, b), a * b
+	}
+}
+
+func (r RandMathCaptcha) GenRandImg(opts Options) (model.Answer, []byte) {
+	expr, result := randExpr(r.rand)
+	return model.Answer{Token: strconv.Itoa(result)}, r.renderer.render(expr, opts)
+}
+
+// result 返回答案对应的计算结果，答案令牌为结果的十进制表示
+func result(answer model.Answer) (int, bool) {
+	n, err := strconv.Atoi(answer.Token)
+	return n, err == nil
+}
+
+// GenChoices 以正确结果附近的数作为干扰项
+func (r RandMathCaptcha) GenChoices(answer model.Answer, n int, _ Options) []string {
+	want, ok := result(answer)
+	if !ok {
+		return nil
+	}
+	var candidates []int
+	for v := want - 10; v <= want+10; v++ {
+		if v >= 0 && v != want {
+			candidates = append(candidates, v)
+		}
+	}
+	r.rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	choices := []string{strconv.Itoa(want)}
+	for i := 0; i < len(candidates) && len(choices) < n; i++ {
+		choices = append(choices, strconv.Itoa(candidates[i]))
+	}
+	r.rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	return choices
+}
+
+// fullWidthDigits 将中文输入法下的全角数字及负号转换为半角
+var fullWidthDigits = strings.NewReplacer(
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9", "－", "-",
+)
+
+func (r RandMathCaptcha) VerifyAnswer(answer, request model.Answer, _ Options) bool {
+	want, ok := result(answer)
+	n, err := strconv.Atoi(fullWidthDigits.Replace(strings.TrimSpace(request.String)))
+	return ok && err == nil && n == want
+}
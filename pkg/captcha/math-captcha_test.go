@@ -0,0 +1,87 @@
+package captcha
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandMathCaptcha(t *testing.T) {
+	c := RandMathCaptcha{rand: newLockedRand(rand.NewSource(1))}
+
+	t.Run("生成算式", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			expr, result := randExpr(r)
+			assert.True(t, strings.HasSuffix(expr, "="))
+			assert.LessOrEqual(t, len([]rune(expr)), 6, expr)
+			assert.GreaterOrEqual(t, result, 0, expr)
+			operands := strings.FieldsFunc(strings.TrimSuffix(expr, "="), func(r rune) bool {
+				return r == '+' || r == '-' || r == '×'
+			})
+			a, _ := strconv.Atoi(operands[0])
+			b, _ := strconv.Atoi(operands[1])
+			switch {
+			case strings.Contains(expr, "+"):
+				assert.Equal(t, a+b, result, expr)
+			case strings.Contains(expr, "-"):
+				assert.Equal(t, a-b, result, expr)
+			default:
+				assert.Equal(t, a*b, result, expr)
+			}
+		}
+	})
+
+	t.Run("生成选项", func(t *testing.T) {
+		for _, n := range []int{0, 3, 12} {
+			answer := model.Answer{Token: strconv.Itoa(n)}
+			choices := c.GenChoices(answer, 4, Options{})
+			assert.Len(t, choices, 4)
+			correct := 0
+			seen := map[string]bool{}
+			for _, v := range choices {
+				assert.False(t, seen[v], "选项不应重复")
+				seen[v] = true
+				if c.VerifyAnswer(answer, model.Answer{String: v}, Options{}) {
+					correct++
+				}
+			}
+			assert.Equal(t, 1, correct)
+		}
+	})
+
+	t.Run("注入随机源", func(t *testing.T) {
+		gen := func() ([]string, []string) {
+			c, err := NewRandMathCaptcha("", RenderOptions{Filters: []FilterOptions{}}, rand.NewSource(42))
+			assert.NoError(t, err)
+			var tokens []string
+			for i := 0; i < 5; i++ {
+				answer, _ := c.GenRandImg(Options{})
+				tokens = append(tokens, answer.Token)
+			}
+			return tokens, c.GenChoices(model.Answer{Token: tokens[0]}, 4, Options{})
+		}
+		tokens, choices := gen()
+		_, want := randExpr(rand.New(rand.NewSource(42)))
+		assert.Equal(t, strconv.Itoa(want), tokens[0])
+		// 相同的随机源得到相同的题目及选项
+		sameTokens, sameChoices := gen()
+		assert.Equal(t, tokens, sameTokens)
+		assert.Equal(t, choices, sameChoices)
+	})
+
+	t.Run("校验答案", func(t *testing.T) {
+		answer := model.Answer{Token: "12"}
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "12"}, Options{}))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: " 12 "}, Options{}))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "１２"}, Options{}))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "13"}, Options{}))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "十二"}, Options{}))
+		assert.False(t, c.VerifyAnswer(model.Answer{}, model.Answer{String: "0"}, Options{}))
+		assert.False(t, c.VerifyAnswer(model.Answer{Token: "x"}, model.Answer{String: "0"}, Options{}))
+	})
+}
@@ -0,0 +1,56 @@
+package captcha
+
+import (
+	"strings"
+	"unicode"
+)
+
+// t2s 为繁体字到简体字的映射
+var t2s = func() map[rune]rune {
+	runes := []rune(t2sPairs)
+	m := make(map[rune]rune, len(runes)/2)
+	for i := 0; i+1 < len(runes); i += 2 {
+		m[runes[i]] = runes[i+1]
+	}
+	return m
+}()
+
+// foldWidth 将全角字母、数字及标点转换为半角，全角空格转换为半角空格
+func foldWidth(c rune) rune {
+	switch {
+	case c == '　':
+		return ' '
+	case c >= '！' && c <= '～':
+		return c - ('！' - '!')
+	}
+	return c
+}
+
+// normalizeWord 去除空白及标点，折叠全角字符并将繁体字转换为简体字
+func normalizeWord(s string) string {
+	return strings.Map(func(c rune) rune {
+		c = foldWidth(c)
+		if unicode.IsSpace(c) || unicode.IsPunct(c) || unicode.IsSymbol(c) {
+			return -1
+		}
+		if simplified, ok := t2s[c]; ok {
+			return simplified
+		}
+		return c
+	}, s)
+}
+
+// matchWord 比较标准化后的答案，maxTypos 为允许错误的字数，不允许多字或少字
+func matchWord(word, input string, maxTypos int) bool {
+	w, in := []rune(normalizeWord(word)), []rune(normalizeWord(input))
+	if len(w) != len(in) {
+		return false
+	}
+	typos := 0
+	for i := range w {
+		if w[i] != in[i] {
+			typos++
+		}
+	}
+	return typos <= maxTypos
+}
@@ -0,0 +1,37 @@
+package captcha
+
+import (
+	"strings"
+	"unicode"
+)
+
+// toneless 为带声调的拼音字母及 ü 对应的无声调字母，ü 统一视为 u，以兼容 lv、lu 等输入习惯
+var toneless = map[rune]rune{
+	'ā': 'a', 'á': 'a', 'ǎ': 'a', 'à': 'a',
+	'ē': 'e', 'é': 'e', 'ě': 'e', 'è': 'e', 'ê': 'e',
+	'ī': 'i', 'í': 'i', 'ǐ': 'i', 'ì': 'i',
+	'ō': 'o', 'ó': 'o', 'ǒ': 'o', 'ò': 'o',
+	'ū': 'u', 'ú': 'u', 'ǔ': 'u', 'ù': 'u',
+	'ǖ': 'u', 'ǘ': 'u', 'ǚ': 'u', 'ǜ': 'u', 'ü': 'u', 'v': 'u',
+	'ń': 'n', 'ň': 'n', 'ǹ': 'n', 'ḿ': 'm',
+}
+
+// normalizePinyin 去除声调（含数字标调）、空白及分隔符并统一为小写，其余字符原样保留以免误判
+func normalizePinyin(s string) string {
+	return strings.Map(func(c rune) rune {
+		c = unicode.ToLower(foldWidth(c))
+		if unicode.IsSpace(c) || unicode.IsPunct(c) || unicode.IsDigit(c) {
+			return -1
+		}
+		if plain, ok := toneless[c]; ok {
+			return plain
+		}
+		return c
+	}, s)
+}
+
+// matchPinyin 比较无声调的拼音，有无空格均可，pinyin 为空时不匹配
+func matchPinyin(pinyin, input string) bool {
+	p := normalizePinyin(pinyin)
+	return p != "" && p == normalizePinyin(input)
+}
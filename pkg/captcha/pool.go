@@ -0,0 +1,164 @@
+package captcha
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// 验证码池的 CloudWatch 指标，维度 captcha 为验证码池名称
+const (
+	metricsNamespace = "drei"
+	metricPoolHit    = "PoolHit"
+	metricPoolMiss   = "PoolMiss"
+)
+
+// metricsOut 为指标的输出位置。Lambda 会将标准输出中 CloudWatch 嵌入式指标格式的日志转换为指标，
+// 各容器的命中及未命中次数因此可在 CloudWatch 中汇总
+var metricsOut io.Writer = os.Stdout
+
+// recordMetric 以嵌入式指标格式记录验证码池 name 的一次命中或未命中
+func recordMetric(name, metric string) {
+	b, err := json.Marshal(map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  metricsNamespace,
+				"Dimensions": [][]string{{"captcha"}},
+				"Metrics":    []map[string]string{{"Name": metric, "Unit": "Count"}},
+			}},
+		},
+		"captcha": name,
+		metric:    1,
+	})
+	if err != nil {
+		log.Println("marshal metric failed: ", err)
+		return
+	}
+	_, _ = metricsOut.Write(append(b, '\n'))
+}
+
+// Pool 预先生成验证码图片，入群及刷新时直接取用以降低延迟，
+// 每张图片只发出一次，取用后在后台异步补充
+type Pool struct {
+	Interface
+	name string
+	size int
+	// scoped 非 nil 时按其返回的出题范围分别缓存，只有出题范围受选项影响的验证码需要
+	scoped drawScoped
+
+	// genMu 保证同一时间只有一个 goroutine 调用 GenRandImg，出题及绘制按顺序取用随机数，
+	// 同一随机源生成的图片序列可以复现
+	genMu sync.Mutex
+	mu    sync.Mutex
+	pools map[poolKey]*subPool
+}
+
+// poolKey 为 Options 中影响生成图片的选项，选项不同的图片分别缓存，
+// 只影响校验的 Fuzzy 不区分
+type poolKey struct {
+	animated   bool
+	difficulty int
+	pinyin     bool
+}
+
+// drawScoped 由出题范围受 Options 影响的验证码实现，drawScope 返回 opts 中影响出题范围的选项
+type drawScoped interface {
+	drawScope(opts Options) Options
+}
+
+type pooledImg struct {
+	answer model.Answer
+	img    []byte
+}
+
+type subPool struct {
+	opts    Options
+	imgs    chan pooledImg
+	filling int32
+}
+
+// NewPool 为 c 的每组选项缓存 size 张图片，并在后台预先生成默认选项的图片，
+// size 不为正数时直接返回 c。设置了自定义词库的群组不经过缓存
+func NewPool(name string, c Interface, size int) Interface {
+	if size <= 0 {
+		return c
+	}
+	p := &Pool{
+		Interface: c,
+		name:      name,
+		size:      size,
+		pools:     map[poolKey]*subPool{},
+	}
+	if d, ok := c.(drawScoped); ok {
+		p.scoped = d
+	}
+	p.refill(p.subPool(Options{}))
+	return p
+}
+
+func (p *Pool) subPool(opts Options) *subPool {
+	key := poolKey{animated: opts.Animated}
+	if p.scoped != nil {
+		scope := p.scoped.drawScope(opts)
+		key.difficulty, key.pinyin = scope.Difficulty, scope.Pinyin
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sp, ok := p.pools[key]
+	if !ok {
+		sp = &subPool{
+			opts: Options{Animated: key.animated, Difficulty: key.difficulty, Pinyin: key.pinyin},
+			imgs: make(chan pooledImg, p.size),
+		}
+		p.pools[key] = sp
+	}
+	return sp
+}
+
+func (p *Pool) gen(opts Options) (model.Answer, []byte) {
+	p.genMu.Lock()
+	defer p.genMu.Unlock()
+	return p.Interface.GenRandImg(opts)
+}
+
+// refill 在后台将 sp 补满，已有 goroutine 在补充时直接返回
+func (p *Pool) refill(sp *subPool) {
+	if !atomic.CompareAndSwapInt32(&sp.filling, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&sp.filling, 0)
+		for len(sp.imgs) < cap(sp.imgs) {
+			answer, img := p.gen(sp.opts)
+			select {
+			case sp.imgs <- pooledImg{answer: answer, img: img}:
+			default:
+				return
+			}
+		}
+	}()
+}
+
+// GenRandImg 优先取用缓存的图片，缓存为空时同步生成
+func (p *Pool) GenRandImg(opts Options) (model.Answer, []byte) {
+	if len(opts.Words) > 0 {
+		return p.gen(opts)
+	}
+	sp := p.subPool(opts)
+	defer p.refill(sp)
+	select {
+	case v := <-sp.imgs:
+		recordMetric(p.name, metricPoolHit)
+		return v.answer, v.img
+	default:
+		recordMetric(p.name, metricPoolMiss)
+		return p.gen(opts)
+	}
+}
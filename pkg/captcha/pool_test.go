@@ -0,0 +1,96 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var n int64
+	c := NewMockInterface(ctrl)
+	c.EXPECT().GenRandImg(gomock.Any()).DoAndReturn(func(opts Options) (model.Answer, []byte) {
+		token := strconv.FormatInt(atomic.AddInt64(&n, 1), 10)
+		return model.Answer{Token: token}, []byte(token)
+	}).AnyTimes()
+
+	var metrics bytes.Buffer
+	metricsOut = &metrics
+	defer func() { metricsOut = os.Stdout }()
+	stat := func(metric string) int64 {
+		var n int64
+		dec := json.NewDecoder(bytes.NewReader(metrics.Bytes()))
+		for dec.More() {
+			var v map[string]interface{}
+			assert.NoError(t, dec.Decode(&v))
+			if v["captcha"] == "test" && v[metric] != nil {
+				n++
+			}
+		}
+		return n
+	}
+	filled := func(p *Pool, opts Options) func() bool {
+		return func() bool {
+			sp := p.subPool(opts)
+			return len(sp.imgs) == cap(sp.imgs) && atomic.LoadInt32(&sp.filling) == 0
+		}
+	}
+
+	assert.Equal(t, c, NewPool("disabled", c, 0))
+
+	p := NewPool("test", c, 3).(*Pool)
+
+	t.Run("预先生成的图片不重复发出", func(t *testing.T) {
+		assert.Eventually(t, filled(p, Options{}), time.Second, time.Millisecond)
+		seen := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			answer, img := p.GenRandImg(Options{})
+			assert.Equal(t, answer.Token, string(img))
+			assert.False(t, seen[answer.Token], answer.Token)
+			seen[answer.Token] = true
+		}
+		assert.Equal(t, int64(3), stat(metricPoolHit))
+		assert.Eventually(t, filled(p, Options{}), time.Second, time.Millisecond)
+	})
+
+	t.Run("缓存为空时同步生成", func(t *testing.T) {
+		opts := Options{Animated: true}
+		answer, img := p.GenRandImg(opts)
+		assert.Equal(t, answer.Token, string(img))
+		assert.Equal(t, int64(1), stat(metricPoolMiss))
+		assert.Eventually(t, filled(p, opts), time.Second, time.Millisecond)
+
+		p.GenRandImg(opts)
+		assert.Equal(t, int64(4), stat(metricPoolHit))
+	})
+
+	t.Run("自定义词库不经过缓存", func(t *testing.T) {
+		p.GenRandImg(Options{Words: []string{"对牛弹琴"}})
+		assert.Equal(t, int64(4), stat(metricPoolHit))
+		assert.Equal(t, int64(1), stat(metricPoolMiss))
+		assert.Len(t, p.pools, 2)
+	})
+
+	t.Run("只按影响图片的选项分别缓存", func(t *testing.T) {
+		assert.True(t, p.subPool(Options{}) == p.subPool(Options{Fuzzy: true, Pinyin: true, Difficulty: model.IdiomDifficultyEasy}))
+
+		scoped, ok := Interface(&RandIdiomCaptcha{}).(drawScoped)
+		assert.True(t, ok)
+		idiom := &Pool{size: 1, pools: map[poolKey]*subPool{}, scoped: scoped}
+		assert.True(t, idiom.subPool(Options{}) == idiom.subPool(Options{Fuzzy: true}))
+		assert.False(t, idiom.subPool(Options{}) == idiom.subPool(Options{Difficulty: model.IdiomDifficultyEasy}))
+		assert.False(t, idiom.subPool(Options{}) == idiom.subPool(Options{Pinyin: true}))
+		assert.Equal(t, Options{Pinyin: true}, idiom.subPool(Options{Fuzzy: true, Pinyin: true}).opts)
+	})
+}
@@ -0,0 +1,56 @@
+package captcha
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// intn 为出题所需的随机数接口，测试中可直接传入 *rand.Rand
+type intn interface {
+	Intn(n int) int
+}
+
+// lockedRand 为并发安全的随机数生成器，后台预先生成图片时与处理消息的 goroutine 共用
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newLockedRand 以 src 生成随机数，src 为 nil 时使用以 crypto/rand 为种子的随机源，
+// 避免同时入群的用户因时间种子相同而得到相同的题目
+func newLockedRand(src rand.Source) *lockedRand {
+	if src == nil {
+		src = rand.NewSource(cryptoSeed())
+	}
+	return &lockedRand{r: rand.New(src)}
+}
+
+// cryptoSeed 读取 crypto/rand 失败时退回以当前时间为种子
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63()
+}
+
+func (l *lockedRand) Shuffle(n int, swap func(i, j int)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.r.Shuffle(n, swap)
+}
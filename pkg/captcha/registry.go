@@ -0,0 +1,82 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Plugin 为可按名称选用的验证码类型，内置的成语、算术及字母数字验证码也以插件形式注册
+type Plugin struct {
+	// Name 为验证码名称，记录于群组设置及验证记录中，注册后不应修改
+	Name string
+	// Title 为群组设置中显示的名称
+	Title string
+	// Hint 为验证码消息中的答题提示
+	Hint string
+	// NewConfig 返回填充了默认值的配置结构体指针，即配置的格式，JSON 配置按字段解码至其中
+	NewConfig func() interface{}
+	// Factory 以 NewConfig 返回并解码了配置的结构体创建验证码
+	Factory func(config interface{}) (Interface, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Plugin{}
+	// registryOrder 为注册顺序，群组设置按此顺序切换验证码类型
+	registryOrder []string
+)
+
+// Register 注册验证码插件，应在插件包的 init 中调用，名称为空、重复或缺少 NewConfig 及 Factory 时 panic
+func Register(p Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if p.Name == "" || p.NewConfig == nil || p.Factory == nil {
+		panic("captcha: 插件缺少名称、NewConfig 或 Factory")
+	}
+	if _, ok := registry[p.Name]; ok {
+		panic("captcha: 重复注册插件 " + p.Name)
+	}
+	registry[p.Name] = p
+	registryOrder = append(registryOrder, p.Name)
+}
+
+// Lookup 返回名为 name 的插件
+func Lookup(name string) (Plugin, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names 按注册顺序返回全部插件名称
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, len(registryOrder))
+	copy(names, registryOrder)
+	return names
+}
+
+// New 以 JSON 配置创建名为 name 的验证码，config 为空时使用默认配置，配置中不允许出现未知字段
+func New(name string, config []byte) (Interface, error) {
+	p, ok := Lookup(name)
+	if !ok {
+		return nil, xerrors.Errorf("未注册的验证码: %s", name)
+	}
+	cfg := p.NewConfig()
+	if len(bytes.TrimSpace(config)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(config))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, xerrors.Errorf("解码验证码 %s 的配置失败: %w", name, err)
+		}
+	}
+	c, err := p.Factory(cfg)
+	if err != nil {
+		return nil, xerrors.Errorf("创建验证码 %s 失败: %w", name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,68 @@
+package captcha
+
+import (
+	"testing"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("内置验证码", func(t *testing.T) {
+		assert.Equal(t, []string{model.CaptchaTypeIdiom, model.CaptchaTypeMath, model.CaptchaTypeText}, Names()[:3])
+		p, ok := Lookup(model.CaptchaTypeMath)
+		assert.True(t, ok)
+		assert.Equal(t, model.CaptchaTypeNames[model.CaptchaTypeMath], p.Title)
+		assert.Equal(t, model.CaptchaHints[model.CaptchaTypeMath], p.Hint)
+
+		c, err := New(model.CaptchaTypeText, []byte(`{"length": 6, "charset": "ab"}`))
+		assert.NoError(t, err)
+		text := c.(*RandTextCaptcha)
+		assert.Equal(t, 6, text.length)
+		assert.Equal(t, []rune("AB"), text.charset)
+
+		c, err = New(model.CaptchaTypeText, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultTextLength, c.(*RandTextCaptcha).length)
+
+		_, err = New(model.CaptchaTypeText, []byte(`{"length": 0}`))
+		assert.Error(t, err)
+		_, err = New(model.CaptchaTypeText, []byte(`{"lenght": 6}`))
+		assert.Error(t, err)
+		_, err = New("missing", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("注册插件", func(t *testing.T) {
+		type config struct {
+			Answer string `json:"answer"`
+		}
+		var got config
+		Register(Plugin{
+			Name:  "test-plugin",
+			Title: "测试",
+			NewConfig: func() interface{} {
+				return &config{Answer: "默认"}
+			},
+			Factory: func(cfg interface{}) (Interface, error) {
+				got = *cfg.(*config)
+				return RandMathCaptcha{}, nil
+			},
+		})
+		assert.Equal(t, "test-plugin", Names()[len(Names())-1])
+
+		_, err := New("test-plugin", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "默认", got.Answer)
+		_, err = New("test-plugin", []byte(`{"answer": "42"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "42", got.Answer)
+
+		assert.Panics(t, func() {
+			Register(Plugin{Name: "test-plugin", NewConfig: func() interface{} { return nil }, Factory: func(interface{}) (Interface, error) { return nil, nil }})
+		})
+		assert.Panics(t, func() {
+			Register(Plugin{Name: "no-factory"})
+		})
+	})
+}
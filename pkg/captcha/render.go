@@ -0,0 +1,325 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hanguofeng/freetype-go-mirror/freetype/truetype"
+	"golang.org/x/xerrors"
+)
+
+// 可在 RenderOptions.Filters 中使用的滤镜
+const (
+	FilterNoiseLine  = "noiseLine"
+	FilterNoisePoint = "noisePoint"
+	FilterStrike     = "strike"
+)
+
+var filterNames = map[string]bool{
+	FilterNoiseLine:  true,
+	FilterNoisePoint: true,
+	FilterStrike:     true,
+}
+
+const (
+	defaultImgWidth = 320
+	defaultNoiseNum = 180
+	// 深色模式未指定颜色时使用的背景色及文字颜色
+	darkBackground = "#1e1e1e"
+	darkForeground = "#e8e8e8"
+)
+
+// FilterOptions 为单个滤镜的参数，Num 为干扰线、噪点或删除线的数量
+type FilterOptions struct {
+	Name string `json:"name"`
+	Num  int    `json:"num"`
+}
+
+// RenderOptions 为验证码图片的绘制参数，零值字段使用各验证码的默认值，
+// 以便在不同部署中权衡可读性与抗识别能力
+type RenderOptions struct {
+	Width int `json:"width"`
+	// Height 为 0 时随字号调整
+	Height   int     `json:"height"`
+	FontSize float64 `json:"fontSize"`
+	// FontFiles 中的相对路径相对于字体目录，未设置时使用字体目录中的默认字体，
+	// 未指定字体目录时使用内置字体
+	FontFiles []string `json:"fontFiles"`
+	// Filters 为 nil 时使用默认滤镜，为空列表时不添加干扰
+	Filters []FilterOptions `json:"filters"`
+	// Foreground 及 Background 为 #RRGGBB 格式的文字颜色及背景色，
+	// 均未设置且未开启 DarkMode 时使用随机配色
+	Foreground string `json:"foreground"`
+	Background string `json:"background"`
+	// DarkMode 为未设置的颜色使用深色背景及浅色文字
+	DarkMode bool `json:"darkMode"`
+}
+
+// LoadRenderOptions 从 JSON 文件读取以验证码类型为键的绘制参数
+func LoadRenderOptions(path string) (map[string]RenderOptions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("读取 %s 文件失败: %w", path, err)
+	}
+	defer f.Close()
+	var opts map[string]RenderOptions
+	if err := json.NewDecoder(f).Decode(&opts); err != nil {
+		return nil, xerrors.Errorf("解码绘制参数失败: %w", err)
+	}
+	return opts, nil
+}
+
+// renderer 按绘制参数生成验证码图片，调色板、字体选择及干扰均取自 rand，
+// 与出题共用同一随机源，因此相同的随机源生成相同的题目及图片
+type renderer struct {
+	width, height int
+	fontSize      float64
+	// fontFiles 为字体文件路径，使用内置字体时为空
+	fontFiles []string
+	fonts     []*truetype.Font
+	filters   []FilterOptions
+	rand      *lockedRand
+	// fg 及 bg 为 nil 时不替换对应颜色
+	fg, bg color.Color
+}
+
+// newRenderer 以字体目录中的 fonts 及 fontSize 作为 opts 未设置时的默认值，
+// 默认宽 320 像素，每个字符占 fontSize 像素宽，图片高度随字号调整
+func newRenderer(opts RenderOptions, fontPath string, fonts []string, fontSize float64, rd *lockedRand) (*renderer, error) {
+	if opts.Width < 0 || opts.Height < 0 || opts.FontSize < 0 {
+		return nil, xerrors.Errorf("图片尺寸及字号不能为负数: %dx%d, %v", opts.Width, opts.Height, opts.FontSize)
+	}
+	if opts.Width == 0 {
+		opts.Width = defaultImgWidth
+	}
+	if opts.FontSize == 0 {
+		opts.FontSize = fontSize
+	}
+	if opts.Height == 0 {
+		opts.Height = int(opts.FontSize * 1.25)
+	}
+	r := &renderer{
+		width:    opts.Width,
+		height:   opts.Height,
+		fontSize: opts.FontSize,
+		rand:     rd,
+	}
+	if len(opts.FontFiles) > 0 {
+		fonts = opts.FontFiles
+	}
+	var err error
+	if len(opts.FontFiles) == 0 && fontPath == "" {
+		font, err := truetype.Parse(defaultFont)
+		if err != nil {
+			return nil, xerrors.Errorf("解析内置字体失败: %w", err)
+		}
+		r.fonts = []*truetype.Font{font}
+	} else {
+		for _, v := range fonts {
+			if !filepath.IsAbs(v) {
+				v = filepath.Join(fontPath, v)
+			}
+			r.fontFiles = append(r.fontFiles, v)
+		}
+		if r.fonts, err = loadFonts(r.fontFiles); err != nil {
+			return nil, err
+		}
+	}
+	if r.filters, err = imgFilters(opts.Filters); err != nil {
+		return nil, err
+	}
+
+	fg, bg := opts.Foreground, opts.Background
+	if opts.DarkMode {
+		if fg == "" {
+			fg = darkForeground
+		}
+		if bg == "" {
+			bg = darkBackground
+		}
+	}
+	if fg != "" {
+		if r.fg, err = parseHexColor(fg); err != nil {
+			return nil, err
+		}
+	}
+	if bg != "" {
+		if r.bg, err = parseHexColor(bg); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// loadFonts 在启动时加载全部字体文件，任一字体无法加载时返回错误
+func loadFonts(files []string) ([]*truetype.Font, error) {
+	if len(files) == 0 {
+		return nil, xerrors.New("未配置字体文件")
+	}
+	fonts := make([]*truetype.Font, 0, len(files))
+	for _, v := range files {
+		b, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, xerrors.Errorf("读取字体 %s 失败: %w", v, err)
+		}
+		font, err := truetype.Parse(b)
+		if err != nil {
+			return nil, xerrors.Errorf("解析字体 %s 失败: %w", v, err)
+		}
+		fonts = append(fonts, font)
+	}
+	return fonts, nil
+}
+
+// parseHexColor 解析 #RRGGBB 格式的颜色，# 可省略
+func parseHexColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, xerrors.Errorf("颜色须为 #RRGGBB 格式: %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, xerrors.Errorf("颜色须为 #RRGGBB 格式: %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// cjkFonts 为字体目录中包含中文字形的字体，供成语及算式验证码使用
+var cjkFonts = []string{"STFANGSO.ttf", "STHEITI.ttf", "STXIHEI.ttf"}
+
+// latinFonts 为字体目录中的拉丁字母及数字字体，供字母数字验证码使用
+var latinFonts = []string{"DejaVuSans-Bold.ttf", "DejaVuSerif-Bold.ttf", "DejaVuSansMono-Bold.ttf"}
+
+// imgFilters 校验滤镜参数，opts 为 nil 时干扰线、噪点及删除线各添加 180 个
+func imgFilters(opts []FilterOptions) ([]FilterOptions, error) {
+	if opts == nil {
+		return []FilterOptions{
+			{Name: FilterNoiseLine, Num: defaultNoiseNum},
+			{Name: FilterNoisePoint, Num: defaultNoiseNum},
+			{Name: FilterStrike, Num: defaultNoiseNum},
+		}, nil
+	}
+	for _, v := range opts {
+		if !filterNames[v.Name] {
+			return nil, xerrors.Errorf("不支持的滤镜: %q", v.Name)
+		}
+		if v.Num < 0 {
+			return nil, xerrors.Errorf("滤镜 %s 的数量不能为负数: %d", v.Name, v.Num)
+		}
+	}
+	return opts, nil
+}
+
+// draw 将 text 绘制为经滤镜处理的调色板图片。每张图片从 r.rand 取一个种子，
+// 绘制过程中不再持有锁，并发绘制时每张图片仍只由其种子决定
+func (r *renderer) draw(text string) *image.Paletted {
+	c := newCanvas(r.width, r.height, rand.New(rand.NewSource(r.rand.Int63())))
+	c.drawText(r.fonts, r.fontSize, text)
+	// 文字以黑色绘制于白色背景，调色板随机生成，
+	// 记录最接近黑白两色的调色板下标以便替换配色
+	fgIdx := c.Palette.Index(color.Black)
+	bgIdx := c.Palette.Index(color.White)
+	for _, f := range r.filters {
+		c.applyFilter(f)
+	}
+	recolor(c.Paletted, fgIdx, bgIdx, r.fg, r.bg)
+	return c.Paletted
+}
+
+// recolor 替换调色板中的文字颜色及背景色，两者为同一下标时只替换背景色
+func recolor(img *image.Paletted, fgIdx, bgIdx int, fg, bg color.Color) {
+	if fg == nil && bg == nil {
+		return
+	}
+	palette := make(color.Palette, len(img.Palette))
+	copy(palette, img.Palette)
+	if fg != nil && fgIdx != bgIdx {
+		palette[fgIdx] = fg
+	}
+	if bg != nil {
+		palette[bgIdx] = bg
+	}
+	img.Palette = palette
+}
+
+// renderImg 将 text 绘制为 png 图片
+func (r *renderer) renderImg(text string) []byte {
+	captchaBuffer := bytes.NewBuffer([]byte{})
+	if err := png.Encode(captchaBuffer, r.draw(text)); err != nil {
+		log.Fatalln("encode png img failed: ", err)
+	}
+	return captchaBuffer.Bytes()
+}
+
+const (
+	// gifFrameDelay 为动图每帧的停留时长，单位为 1/100 秒
+	gifFrameDelay = 30
+	// gifCycles 为动图中逐字显示的轮数，每轮中每个字符各起始显示一帧
+	gifCycles = 2
+	// gifRevealed 为动图每帧最多显示的字符数
+	gifRevealed = 2
+)
+
+// gifFrames 返回动图各帧的文字，第 i 帧从第 i 个字符起依次显示 gifRevealed 个字符，
+// 其余字符以空格占位，位置保持不变。不超过 3 个字符时每帧只显示 1 个字符，
+// 以免单帧中出现大部分验证码内容
+func gifFrames(text string) []string {
+	runes := []rune(text)
+	revealed := gifRevealed
+	if len(runes) <= 3 {
+		revealed = 1
+	}
+	frames := make([]string, 0, len(runes)*gifCycles)
+	for i := 0; i < len(runes)*gifCycles; i++ {
+		frame := make([]rune, len(runes))
+		for j := range frame {
+			frame[j] = ' '
+		}
+		for j := 0; j < revealed; j++ {
+			k := (i + j) % len(runes)
+			frame[k] = runes[k]
+		}
+		frames = append(frames, string(frame))
+	}
+	return frames
+}
+
+// renderGIF 将 text 绘制为循环播放的动图，每一帧只显示一至两个字符并重新生成干扰，
+// 以防止截取单帧进行识别
+func (r *renderer) renderGIF(text string) []byte {
+	anim := &gif.GIF{}
+	for _, frame := range gifFrames(text) {
+		anim.Image = append(anim.Image, r.draw(frame))
+		anim.Delay = append(anim.Delay, gifFrameDelay)
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+	anim.Config = image.Config{
+		ColorModel: anim.Image[0].Palette,
+		Width:      r.width,
+		Height:     r.height,
+	}
+	captchaBuffer := bytes.NewBuffer([]byte{})
+	if err := gif.EncodeAll(captchaBuffer, anim); err != nil {
+		log.Fatalln("encode gif img failed: ", err)
+	}
+	return captchaBuffer.Bytes()
+}
+
+// render 按 opts 将 text 绘制为静态图片或动图
+func (r *renderer) render(text string, opts Options) []byte {
+	if opts.Animated {
+		return r.renderGIF(text)
+	}
+	return r.renderImg(text)
+}
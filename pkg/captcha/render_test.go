@@ -0,0 +1,208 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// fontDir 返回以 names 为文件名写入内置字体的临时目录
+func fontDir(t *testing.T, names ...string) string {
+	dir, err := ioutil.TempDir("", "fonts-*")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for _, v := range names {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, v), defaultFont, 0644))
+	}
+	return dir
+}
+
+func TestRenderOptions(t *testing.T) {
+	t.Run("默认参数", func(t *testing.T) {
+		dir := fontDir(t, cjkFonts...)
+		r, err := newRenderer(RenderOptions{}, dir, cjkFonts, 80, newLockedRand(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 320, r.width)
+		assert.Equal(t, 100, r.height)
+		assert.Equal(t, float64(80), r.fontSize)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "STFANGSO.ttf"),
+			filepath.Join(dir, "STHEITI.ttf"),
+			filepath.Join(dir, "STXIHEI.ttf"),
+		}, r.fontFiles)
+		assert.Len(t, r.fonts, 3)
+		assert.Len(t, r.filters, 3)
+		assert.Nil(t, r.fg)
+		assert.Nil(t, r.bg)
+	})
+
+	t.Run("自定义参数", func(t *testing.T) {
+		dir, absFont := fontDir(t, "a.ttf"), filepath.Join(fontDir(t, "b.ttf"), "b.ttf")
+		r, err := newRenderer(RenderOptions{
+			Width:      400,
+			FontSize:   60,
+			FontFiles:  []string{"a.ttf", absFont},
+			Filters:    []FilterOptions{{Name: FilterNoisePoint, Num: 50}},
+			DarkMode:   true,
+			Background: "#000000",
+		}, dir, cjkFonts, 80, newLockedRand(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 400, r.width)
+		assert.Equal(t, 75, r.height)
+		assert.Equal(t, []string{filepath.Join(dir, "a.ttf"), absFont}, r.fontFiles)
+		assert.Equal(t, []FilterOptions{{Name: FilterNoisePoint, Num: 50}}, r.filters)
+		assert.Equal(t, color.RGBA{0xe8, 0xe8, 0xe8, 0xff}, r.fg)
+		assert.Equal(t, color.RGBA{0, 0, 0, 0xff}, r.bg)
+
+		r, err = newRenderer(RenderOptions{Filters: []FilterOptions{}}, "", nil, 80, newLockedRand(nil))
+		assert.NoError(t, err)
+		assert.Empty(t, r.filters)
+	})
+
+	t.Run("内置字体", func(t *testing.T) {
+		r, err := newRenderer(RenderOptions{}, "", cjkFonts, 80, newLockedRand(nil))
+		assert.NoError(t, err)
+		assert.Empty(t, r.fontFiles)
+		assert.Len(t, r.fonts, 1)
+
+		img, err := png.Decode(bytes.NewReader(r.render("画蛇添足", Options{})))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 320, 100), img.Bounds())
+
+		anim, err := gif.DecodeAll(bytes.NewReader(r.render("画蛇添足", Options{Animated: true})))
+		assert.NoError(t, err)
+		assert.Len(t, anim.Image, 4*gifCycles)
+	})
+
+	t.Run("相同随机源绘制相同的图片", func(t *testing.T) {
+		render := func(seed int64, opts Options) []byte {
+			r, err := newRenderer(RenderOptions{}, "", nil, 80, newLockedRand(rand.NewSource(seed)))
+			assert.NoError(t, err)
+			return r.render("画蛇添足", opts)
+		}
+		for _, opts := range []Options{{}, {Animated: true}} {
+			assert.Equal(t, render(1, opts), render(1, opts), opts)
+			assert.NotEqual(t, render(1, opts), render(2, opts), opts)
+		}
+
+		// 同一验证码以相同随机源创建时，题目及图片均相同
+		gen := func() (model.Answer, []byte) {
+			c, err := NewRandIdiomCaptcha("", "", 0, RenderOptions{}, rand.NewSource(1))
+			assert.NoError(t, err)
+			return c.GenRandImg(Options{})
+		}
+		answer, img := gen()
+		answer2, img2 := gen()
+		assert.Equal(t, answer, answer2)
+		assert.Equal(t, img, img2)
+	})
+
+	t.Run("动图每帧只显示部分字符", func(t *testing.T) {
+		assert.Equal(t, []string{"画蛇  ", " 蛇添 ", "  添足", "画  足", "画蛇  ", " 蛇添 ", "  添足", "画  足"}, gifFrames("画蛇添足"))
+		assert.Equal(t, []string{"1  ", " 2 ", "  3", "1  ", " 2 ", "  3"}, gifFrames("123"))
+
+		// 不添加干扰，按每个字符所占的 fontSize 像素宽统计每帧中有文字的位置
+		r, err := newRenderer(RenderOptions{Filters: []FilterOptions{}}, "", nil, 80, newLockedRand(rand.NewSource(1)))
+		assert.NoError(t, err)
+		anim, err := gif.DecodeAll(bytes.NewReader(r.render("画蛇添足", Options{Animated: true})))
+		assert.NoError(t, err)
+		shown := map[int]bool{}
+		for i, frame := range anim.Image {
+			// 背景为调色板中的透明色，解码后颜色会改变，因此以出现最多的颜色作为背景
+			count := map[uint8]int{}
+			var bg uint8
+			for _, v := range frame.Pix {
+				if count[v]++; count[v] > count[bg] {
+					bg = v
+				}
+			}
+			var slots []int
+			for slot := 0; slot < 4; slot++ {
+				ink := 0
+				for x := slot * 80; x < (slot+1)*80; x++ {
+					for y := 0; y < frame.Bounds().Dy(); y++ {
+						if frame.ColorIndexAt(x, y) != bg {
+							ink++
+						}
+					}
+				}
+				// 忽略相邻字符越界的少量笔画
+				if ink > 100 {
+					slots = append(slots, slot)
+					shown[slot] = true
+				}
+			}
+			assert.Len(t, slots, gifRevealed, i)
+		}
+		assert.Len(t, shown, 4)
+	})
+
+	t.Run("字体无法加载", func(t *testing.T) {
+		dir := fontDir(t, "STFANGSO.ttf")
+		_, err := newRenderer(RenderOptions{}, dir, cjkFonts, 80, newLockedRand(nil))
+		assert.Error(t, err)
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.ttf"), []byte("not a font"), 0644))
+		_, err = newRenderer(RenderOptions{FontFiles: []string{"broken.ttf"}}, dir, nil, 80, newLockedRand(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		for _, v := range []RenderOptions{
+			{Width: -1},
+			{FontSize: -1},
+			{Filters: []FilterOptions{{Name: "blur"}}},
+			{Filters: []FilterOptions{{Name: FilterStrike, Num: -1}}},
+			{Foreground: "red"},
+			{Background: "#12345g"},
+		} {
+			_, err := newRenderer(v, "", nil, 80, newLockedRand(nil))
+			assert.Error(t, err, v)
+		}
+	})
+
+	t.Run("替换配色", func(t *testing.T) {
+		img := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.White, color.Black, color.Gray{0x80}})
+		red, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+		palette := img.Palette
+		recolor(img, 1, 0, red, blue)
+		assert.Equal(t, color.Palette{blue, red, color.Gray{0x80}}, img.Palette)
+		// 不修改原调色板
+		assert.Equal(t, color.White, palette[0])
+
+		img.Palette = color.Palette{color.White}
+		recolor(img, 0, 0, red, blue)
+		assert.Equal(t, color.Palette{blue}, img.Palette)
+	})
+
+	t.Run("读取配置文件", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "render-*.json")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(`{"idiom": {"width": 360, "darkMode": true, "filters": [{"name": "strike", "num": 2}]}}`)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		opts, err := LoadRenderOptions(f.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, RenderOptions{
+			Width:    360,
+			DarkMode: true,
+			Filters:  []FilterOptions{{Name: FilterStrike, Num: 2}},
+		}, opts[model.CaptchaTypeIdiom])
+		assert.Equal(t, RenderOptions{}, opts[model.CaptchaTypeMath])
+
+		_, err = LoadRenderOptions(f.Name() + ".missing")
+		assert.Error(t, err)
+	})
+}
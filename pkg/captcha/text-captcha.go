@@ -0,0 +1,114 @@
+package captcha
+
+import (
+	"math/rand"
+	"strings"
+	"unicode"
+
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// DefaultTextCharset 为默认字符集，已排除 0/O、1/I/l 等易混淆字符
+	DefaultTextCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	DefaultTextLength  = 5
+	MaxTextLength      = 8
+)
+
+// RandTextCaptcha 生成由字母及数字组成的验证码，校验时不区分大小写，适用于不使用中文的群组，答案令牌即验证码内容
+type RandTextCaptcha struct {
+	charset  []rune
+	length   int
+	renderer *renderer
+	rand     *lockedRand
+}
+
+// NewRandTextCaptcha 的 charset 中的字符统一转换为大写并去重，只允许字母及数字，fontPath 为空时使用内置字体，
+// src 为出题、绘制图片及生成选项使用的随机源，为 nil 时使用以 crypto/rand 为种子的随机源
+func NewRandTextCaptcha(fontPath string, length int, charset string, renderOpts RenderOptions, src rand.Source) (Interface, error) {
+	if length < 1 || length > MaxTextLength {
+		return nil, xerrors.Errorf("验证码长度须在 1 至 %d 之间: %d", MaxTextLength, length)
+	}
+	var runes []rune
+	seen := map[rune]bool{}
+	for _, c := range charset {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return nil, xerrors.Errorf("字符集只能包含字母及数字: %q", c)
+		}
+		c = unicode.ToUpper(c)
+		if !seen[c] {
+			seen[c] = true
+			runes = append(runes, c)
+		}
+	}
+	if len(runes) < 2 {
+		return nil, xerrors.Errorf("字符集至少需要包含 2 个不同字符: %q", charset)
+	}
+	fontSize := float64(312 / length)
+	if fontSize > 64 {
+		fontSize = 64
+	}
+	rd := newLockedRand(src)
+	renderer, err := newRenderer(renderOpts, fontPath, latinFonts, fontSize, rd)
+	if err != nil {
+		return nil, err
+	}
+	return &RandTextCaptcha{
+		charset:  runes,
+		length:   length,
+		renderer: renderer,
+		rand:     rd,
+	}, nil
+}
+
+// randText 返回随机验证码内容
+func (r RandTextCaptcha) randText(rd intn) string {
+	text := make([]rune, r.length)
+	for i := range text {
+		text[i] = r.charset[rd.Intn(len(r.charset))]
+	}
+	return string(text)
+}
+
+func (r RandTextCaptcha) GenRandImg(opts Options) (model.Answer, []byte) {
+	text := r.randText(r.rand)
+	return model.Answer{Token: text}, r.renderer.render(text, opts)
+}
+
+func (r RandTextCaptcha) GenChoices(answer model.Answer, n int, _ Options) []string {
+	text := answer.Token
+	if text == "" {
+		return nil
+	}
+	picked := map[string]bool{text: true}
+	choices := []string{text}
+	// 字符集及长度过小时可能凑不齐 n 个选项，尝试有限次数后放弃
+	for i := 0; len(choices) < n && i < n*100; i++ {
+		candidate := r.randText(r.rand)
+		if picked[candidate] {
+			continue
+		}
+		picked[candidate] = true
+		choices = append(choices, candidate)
+	}
+	r.rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	return choices
+}
+
+// normalizeText 去除空白，将全角字母及数字转换为半角并统一为大写
+func normalizeText(s string) string {
+	return strings.Map(func(c rune) rune {
+		c = foldWidth(c)
+		if unicode.IsSpace(c) {
+			return -1
+		}
+		return unicode.ToUpper(c)
+	}, s)
+}
+
+func (r RandTextCaptcha) VerifyAnswer(answer, request model.Answer, _ Options) bool {
+	return answer.Token != "" && normalizeText(request.String) == answer.Token
+}
@@ -0,0 +1,90 @@
+package captcha
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandTextCaptcha(t *testing.T) {
+	t.Run("无效配置", func(t *testing.T) {
+		for _, v := range []struct {
+			length  int
+			charset string
+		}{
+			{0, DefaultTextCharset},
+			{MaxTextLength + 1, DefaultTextCharset},
+			{4, "aA"},
+			{4, "AB-"},
+			{4, ""},
+		} {
+			_, err := NewRandTextCaptcha("", v.length, v.charset, RenderOptions{}, nil)
+			assert.Error(t, err, "%d %q", v.length, v.charset)
+		}
+	})
+
+	t.Run("生成验证码", func(t *testing.T) {
+		c, err := NewRandTextCaptcha("", 6, "abcXYZ789", RenderOptions{}, nil)
+		assert.NoError(t, err)
+		r := c.(*RandTextCaptcha)
+		rd := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			text := r.randText(rd)
+			assert.Len(t, []rune(text), 6)
+			for _, ch := range text {
+				assert.Contains(t, "ABCXYZ789", string(ch))
+			}
+		}
+	})
+
+	t.Run("生成选项", func(t *testing.T) {
+		c, err := NewRandTextCaptcha("", DefaultTextLength, DefaultTextCharset, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		answer := model.Answer{Token: c.(*RandTextCaptcha).randText(rand.New(rand.NewSource(1)))}
+		choices := c.GenChoices(answer, 4, Options{})
+		assert.Len(t, choices, 4)
+		correct := 0
+		seen := map[string]bool{}
+		for _, v := range choices {
+			assert.False(t, seen[v], "选项不应重复")
+			seen[v] = true
+			if c.VerifyAnswer(answer, model.Answer{String: v}, Options{}) {
+				correct++
+			}
+		}
+		assert.Equal(t, 1, correct)
+	})
+
+	t.Run("注入随机源", func(t *testing.T) {
+		gen := func() ([]string, []string) {
+			c, err := NewRandTextCaptcha("", DefaultTextLength, DefaultTextCharset, RenderOptions{Filters: []FilterOptions{}}, rand.NewSource(42))
+			assert.NoError(t, err)
+			var tokens []string
+			for i := 0; i < 5; i++ {
+				answer, _ := c.GenRandImg(Options{})
+				tokens = append(tokens, answer.Token)
+			}
+			return tokens, c.GenChoices(model.Answer{Token: tokens[0]}, 4, Options{})
+		}
+		tokens, choices := gen()
+		// 相同的随机源得到相同的题目及选项
+		sameTokens, sameChoices := gen()
+		assert.Equal(t, tokens, sameTokens)
+		assert.Equal(t, choices, sameChoices)
+	})
+
+	t.Run("校验答案", func(t *testing.T) {
+		c, err := NewRandTextCaptcha("", 4, DefaultTextCharset, RenderOptions{}, nil)
+		assert.NoError(t, err)
+		answer := model.Answer{Token: "AB23"}
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "AB23"}, Options{}))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "ab23"}, Options{}))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: " aB 23 "}, Options{}))
+		assert.True(t, c.VerifyAnswer(answer, model.Answer{String: "ａｂ２３"}, Options{}))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "AB2"}, Options{}))
+		assert.False(t, c.VerifyAnswer(answer, model.Answer{String: "AB24"}, Options{}))
+		assert.False(t, c.VerifyAnswer(model.Answer{}, model.Answer{String: ""}, Options{}))
+	})
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+// auditPutAttempts 为排序键冲突时写入审计事件的最大尝试次数
+const auditPutAttempts = 5
+
+// Audit 以 chatID:userID 为分区键、事件时间（纳秒）为排序键保存审计日志
+type Audit struct {
+	db        *dynamodb.DynamoDB
+	tableName *string
+}
+
+func NewAudit(p client.ConfigProvider, tableName string) IAudit {
+	return &Audit{
+		db:        dynamodb.New(p),
+		tableName: &tableName,
+	}
+}
+
+func (a Audit) scope(chatID int64, userID int) *string {
+	return aws.String(strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(userID))
+}
+
+func (a Audit) AppendEvent(ctx context.Context, event model.AuditEvent) error {
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return xerrors.Errorf("marshal audit event failed: %w", err)
+	}
+	item["scope"] = &dynamodb.AttributeValue{S: a.scope(event.ChatID, event.UserID)}
+	// 同一用户的多个事件可能记录于同一纳秒，排序键已存在时顺延 1 纳秒重试，
+	// 以免后写入的事件丢失，同时保持事件的先后顺序
+	at := event.Time.UnixNano()
+	for i := 0; i < auditPutAttempts; i++ {
+		item["at"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(at+int64(i), 10))}
+		_, err = a.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			Item:                item,
+			TableName:           a.tableName,
+			ConditionExpression: aws.String("attribute_not_exists(#scope)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#scope": aws.String("scope"),
+			},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		if err != nil {
+			return xerrors.Errorf("append audit event failed: %w", err)
+		}
+		return nil
+	}
+	return xerrors.Errorf("append audit event failed after %d attempts: %w", auditPutAttempts, err)
+}
+
+func (a Audit) ListEvents(ctx context.Context, chatID int64, userID int, limit int) ([]model.AuditEvent, error) {
+	rst, err := a.db.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              a.tableName,
+		KeyConditionExpression: aws.String("#scope = :scope"),
+		ExpressionAttributeNames: map[string]*string{
+			"#scope": aws.String("scope"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":scope": {S: a.scope(chatID, userID)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("list audit events failed: %w", err)
+	}
+	events := make([]model.AuditEvent, 0, len(rst.Items))
+	if err := dynamodbattribute.UnmarshalListOfMaps(rst.Items, &events); err != nil {
+		return nil, xerrors.Errorf("unmarshal audit events failed: %v: %w", err, ErrMalformedItem)
+	}
+	return events, nil
+}
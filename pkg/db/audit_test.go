@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAudit 返回不发送请求的 Audit，每次 PutItem 的排序键记录于 ats，
+// 前 conflicts 次写入返回条件检查失败
+func stubAudit(t *testing.T, conflicts int, ats *[]string) *Audit {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	assert.NoError(t, err)
+	a := NewAudit(sess, "audit").(*Audit)
+	a.db.Handlers.Send.Clear()
+	a.db.Handlers.Send.PushBack(func(r *request.Request) {
+		*ats = append(*ats, *r.Params.(*dynamodb.PutItemInput).Item["at"].N)
+		if len(*ats) <= conflicts {
+			r.Error = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+			return
+		}
+		r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}
+	})
+	return a
+}
+
+func TestAuditAppendEvent(t *testing.T) {
+	ctx := context.Background()
+	event := model.AuditEvent{ChatID: 1, UserID: 2, Type: model.AuditEventJoin, Time: time.Unix(0, 100)}
+
+	t.Run("排序键冲突时顺延重试", func(t *testing.T) {
+		var ats []string
+		assert.NoError(t, stubAudit(t, 2, &ats).AppendEvent(ctx, event))
+		assert.Equal(t, []string{"100", "101", "102"}, ats)
+	})
+
+	t.Run("重试次数用完", func(t *testing.T) {
+		var ats []string
+		assert.Error(t, stubAudit(t, auditPutAttempts, &ats).AppendEvent(ctx, event))
+		assert.Len(t, ats, auditPutAttempts)
+	})
+}
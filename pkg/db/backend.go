@@ -0,0 +1,55 @@
+package db
+
+import (
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/xerrors"
+)
+
+// 验证记录的存储后端，由 BLACKLIST_BACKEND 环境变量选择，未设置时使用 DynamoDB
+const (
+	BackendDynamoDB = "dynamodb"
+	BackendBolt     = "bolt"
+	BackendRedis    = "redis"
+)
+
+// boltPurgeInterval 为 BoltDB 清理过期记录的间隔
+const boltPurgeInterval = time.Minute
+
+// NewBlacklistFromEnv 按 BLACKLIST_BACKEND 创建验证记录存储：dynamodb 使用 USERS_TABLE_NAME 表；
+// bolt 使用 BOLT_PATH 处的文件；redis 连接 REDIS_URL，键名前缀为 REDIS_KEY_PREFIX。
+// 返回的 close 用于退出时关闭连接或文件。群组设置、审计日志及统计只支持 DynamoDB。
+//
+// BoltDB 文件同一时间只能由一个进程打开，而 bot 与 captchaCountDown 是分别运行的 Lambda 函数，
+// 后打开的进程会在等待文件锁超时后退出，因此在 Lambda 中拒绝使用 bolt
+func NewBlacklistFromEnv(p client.ConfigProvider) (IBlacklist, func() error, error) {
+	switch backend := os.Getenv("BLACKLIST_BACKEND"); backend {
+	case "", BackendDynamoDB:
+		return NewBlacklist(p, os.Getenv("USERS_TABLE_NAME")), func() error { return nil }, nil
+	case BackendBolt:
+		if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+			return nil, nil, xerrors.New("bolt 只能由单个进程打开，不能在分别运行的 Lambda 函数中使用，请改用 dynamodb 或 redis")
+		}
+		path := os.Getenv("BOLT_PATH")
+		if path == "" {
+			return nil, nil, xerrors.New("未设置 BOLT_PATH")
+		}
+		bl, err := NewBoltBlacklist(path, boltPurgeInterval)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bl, bl.Close, nil
+	case BackendRedis:
+		opts, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("解析 REDIS_URL 失败: %w", err)
+		}
+		rdb := redis.NewClient(opts)
+		return NewRedisBlacklist(rdb, os.Getenv("REDIS_KEY_PREFIX")), rdb.Close, nil
+	default:
+		return nil, nil, xerrors.Errorf("不支持的 BLACKLIST_BACKEND: %q", backend)
+	}
+}
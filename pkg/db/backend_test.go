@@ -0,0 +1,72 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlacklistFromEnv(t *testing.T) {
+	setenv := func(t *testing.T, env map[string]string) {
+		for k, v := range env {
+			old, ok := os.LookupEnv(k)
+			assert.NoError(t, os.Setenv(k, v))
+			k := k
+			t.Cleanup(func() {
+				if ok {
+					_ = os.Setenv(k, old)
+				} else {
+					_ = os.Unsetenv(k)
+				}
+			})
+		}
+	}
+
+	t.Run("BoltDB", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "bolt-*")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		setenv(t, map[string]string{"BLACKLIST_BACKEND": BackendBolt, "BOLT_PATH": filepath.Join(dir, "drei.db")})
+		bl, closeBl, err := NewBlacklistFromEnv(nil)
+		assert.NoError(t, err)
+		assert.IsType(t, &BoltBlacklist{}, bl)
+		assert.NoError(t, closeBl())
+		// 关闭后释放文件锁，可以再次打开
+		_, closeBl, err = NewBlacklistFromEnv(nil)
+		assert.NoError(t, err)
+		assert.NoError(t, closeBl())
+
+		setenv(t, map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "bot"})
+		_, _, err = NewBlacklistFromEnv(nil)
+		assert.Error(t, err)
+
+		setenv(t, map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "", "BOLT_PATH": ""})
+		_, _, err = NewBlacklistFromEnv(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		s, err := miniredis.Run()
+		assert.NoError(t, err)
+		defer s.Close()
+		setenv(t, map[string]string{"BLACKLIST_BACKEND": BackendRedis, "REDIS_URL": "redis://" + s.Addr()})
+		bl, closeBl, err := NewBlacklistFromEnv(nil)
+		assert.NoError(t, err)
+		assert.IsType(t, &RedisBlacklist{}, bl)
+		assert.NoError(t, closeBl())
+
+		setenv(t, map[string]string{"REDIS_URL": "http://" + s.Addr()})
+		_, _, err = NewBlacklistFromEnv(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("不支持的后端", func(t *testing.T) {
+		setenv(t, map[string]string{"BLACKLIST_BACKEND": "sqlite"})
+		_, _, err := NewBlacklistFromEnv(nil)
+		assert.Error(t, err)
+	})
+}
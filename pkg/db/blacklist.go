@@ -4,14 +4,21 @@ import (
 	"context"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
 )
 
+// MsgIDIndexName 为以 chatID 为分区键、msgID 为排序键的全局二级索引，
+// 创建索引时 DynamoDB 会自动回填已有记录
+const MsgIDIndexName = "msgID-index"
+
 type Blacklist struct {
 	db        *dynamodb.DynamoDB
 	tableName *string
@@ -42,7 +49,7 @@ func (bl Blacklist) GetItem(ctx context.Context, chatID int64, userID int) (*mod
 	if len(result.Item) == 0 {
 		return nil, ErrNotFound
 	}
-	return bl.unmarshal(result.Item), nil
+	return bl.unmarshal(result.Item)
 }
 
 func (bl Blacklist) i64ToStr(i int64) *string {
@@ -64,28 +71,64 @@ func (bl Blacklist) indexKeys(chatID int64, userID int) map[string]*dynamodb.Att
 	}
 }
 
-func (bl Blacklist) UpdateIdx(ctx context.Context, chatID int64, userID, idx int) {
+func (bl Blacklist) UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error {
+	condition := "refreshes = :refreshes"
+	if refreshes == 0 {
+		// 旧记录没有 refreshes 属性，视为未刷新过
+		condition = "attribute_exists(chatID) AND (attribute_not_exists(refreshes) OR refreshes = :refreshes)"
+	}
 	_, err := bl.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
 		TableName: bl.tableName,
 		Key:       bl.indexKeys(chatID, userID),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":idx": {N: bl.iToStr(idx)},
+			":answer":    {S: aws.String(answer)},
+			":refreshes": {N: bl.iToStr(refreshes)},
+			":next":      {N: bl.iToStr(refreshes + 1)},
+			":refreshAt": {N: bl.i64ToStr(timeToNano(refreshAt))},
+			":choices":   bl.strList(choices),
+		},
+		UpdateExpression:    aws.String("SET answer = :answer, choices = :choices, refreshes = :next, refreshAt = :refreshAt REMOVE idx"),
+		ConditionExpression: aws.String(condition),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrNotFound
+		}
+		return xerrors.Errorf("update item failed: %w", err)
+	}
+	return nil
+}
+
+func (bl Blacklist) IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error) {
+	rst, err := bl.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: bl.tableName,
+		Key:       bl.indexKeys(chatID, userID),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":msgID": {N: bl.iToStr(msgID)},
+			":one":   {N: aws.String("1")},
 		},
-		UpdateExpression: aws.String("SET idx = :idx"),
+		UpdateExpression:    aws.String("ADD attempts :one"),
+		ConditionExpression: aws.String("msgID = :msgID"),
+		ReturnValues:        aws.String(dynamodb.ReturnValueUpdatedNew),
 	})
 	if err != nil {
-		log.Fatalln("update item failed:  ", err)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return 0, ErrNotFound
+		}
+		return 0, xerrors.Errorf("incr attempts failed: %w", err)
 	}
+	return bl.intAttr(rst.Attributes, "attempts")
 }
 
-func (bl Blacklist) CreateItem(ctx context.Context, item model.Blacklist) {
+func (bl Blacklist) CreateItem(ctx context.Context, item model.Blacklist) error {
 	_, err := bl.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		Item:      bl.marshalItem(item),
 		TableName: bl.tableName,
 	})
 	if err != nil {
-		log.Fatalln("create item failed: ", err)
+		return xerrors.Errorf("create item failed: %w", err)
 	}
+	return nil
 }
 
 func (bl Blacklist) marshalItem(item model.Blacklist) map[string]*dynamodb.AttributeValue {
@@ -96,8 +139,8 @@ func (bl Blacklist) marshalItem(item model.Blacklist) map[string]*dynamodb.Attri
 		"chatID": {
 			N: aws.String(strconv.FormatInt(item.ChatID, 10)),
 		},
-		"idx": {
-			N: aws.String(strconv.Itoa(item.Index)),
+		"answer": {
+			S: &item.Answer,
 		},
 		"msgID": {
 			N: aws.String(strconv.Itoa(item.MsgID)),
@@ -111,24 +154,57 @@ func (bl Blacklist) marshalItem(item model.Blacklist) map[string]*dynamodb.Attri
 		"msgTemplate": {
 			S: &item.MsgTemplate,
 		},
+		"attempts": {
+			N: aws.String(strconv.Itoa(item.Attempts)),
+		},
+		"maxAttempts": {
+			N: aws.String(strconv.Itoa(item.MaxAttempts)),
+		},
+		"refreshes": {
+			N: aws.String(strconv.Itoa(item.Refreshes)),
+		},
+		"refreshAt": {
+			N: aws.String(strconv.FormatInt(timeToNano(item.RefreshAt), 10)),
+		},
+		"captchaType": {
+			S: &item.CaptchaType,
+		},
+		"choices": bl.strList(item.Choices),
 	}
 }
 
-func (bl Blacklist) DeleteItem(ctx context.Context, chatID int64, userID int) {
+func (bl Blacklist) strList(l []string) *dynamodb.AttributeValue {
+	list := make([]*dynamodb.AttributeValue, 0, len(l))
+	for _, v := range l {
+		list = append(list, &dynamodb.AttributeValue{S: aws.String(v)})
+	}
+	return &dynamodb.AttributeValue{L: list}
+}
+
+func (bl Blacklist) DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error {
 	_, err := bl.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
 		TableName: bl.tableName,
 		Key:       bl.indexKeys(chatID, userID),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":msgID": {N: bl.iToStr(msgID)},
+		},
+		ConditionExpression: aws.String("msgID = :msgID"),
 	})
 	if err != nil {
-		log.Fatalln("delete item failed: ", err)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrNotFound
+		}
+		return xerrors.Errorf("delete item failed: %w", err)
 	}
+	return nil
 }
 
+// GetItemByMsgID 通过 msgID 全局二级索引精确查找记录
 func (bl Blacklist) GetItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error) {
 	rst, err := bl.db.QueryWithContext(ctx, &dynamodb.QueryInput{
 		TableName:              bl.tableName,
-		KeyConditionExpression: aws.String("chatID = :chatID"),
-		FilterExpression:       aws.String("msgID = :msgID"),
+		IndexName:              aws.String(MsgIDIndexName),
+		KeyConditionExpression: aws.String("chatID = :chatID AND msgID = :msgID"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":chatID": {N: bl.i64ToStr(chatID)},
 			":msgID":  {N: bl.iToStr(msgID)},
@@ -136,42 +212,193 @@ func (bl Blacklist) GetItemByMsgID(ctx context.Context, chatID int64, msgID int)
 		Limit: aws.Int64(1),
 	})
 	if err != nil {
+		// 索引尚未创建或仍在回填时，退回到逐条过滤整个群组分区
+		if msgIDIndexUnavailable(err) {
+			log.Println("msgID index unavailable, fall back to filtering: ", err)
+			return bl.scanItemByMsgID(ctx, chatID, msgID)
+		}
 		return nil, err
 	}
 	if *rst.Count == 0 {
 		return nil, ErrNotFound
 	}
-	return bl.unmarshal(rst.Items[0]), nil
+	return bl.unmarshal(rst.Items[0])
+}
+
+// msgIDIndexUnavailable 判断查询是否因 msgID 索引不存在或仍在回填而失败，
+// DynamoDB 对两者均返回提及索引名称的 ValidationException
+func msgIDIndexUnavailable(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == "ValidationException" && strings.Contains(aerr.Message(), MsgIDIndexName)
 }
 
-func (bl Blacklist) unmarshal(item map[string]*dynamodb.AttributeValue) *model.Blacklist {
-	chatID, err := strconv.ParseInt(*item["chatID"].N, 10, 64)
+func (bl Blacklist) scanItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error) {
+	var found map[string]*dynamodb.AttributeValue
+	err := bl.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              bl.tableName,
+		KeyConditionExpression: aws.String("chatID = :chatID"),
+		FilterExpression:       aws.String("msgID = :msgID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":chatID": {N: bl.i64ToStr(chatID)},
+			":msgID":  {N: bl.iToStr(msgID)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(page.Items) > 0 {
+			found = page.Items[0]
+			return false
+		}
+		return true
+	})
 	if err != nil {
-		log.Fatalf("convert chatID %s to int64 failed", *item["chatID"].N)
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return bl.unmarshal(found)
+}
+
+func (bl Blacklist) numAttr(item map[string]*dynamodb.AttributeValue, name string) (string, error) {
+	v, ok := item[name]
+	if !ok || v.N == nil {
+		return "", xerrors.Errorf("attribute %s missing: %w", name, ErrMalformedItem)
 	}
-	userID, err := strconv.Atoi(*item["userID"].N)
+	return *v.N, nil
+}
+
+func (bl Blacklist) strAttr(item map[string]*dynamodb.AttributeValue, name string) (string, error) {
+	v, ok := item[name]
+	if !ok || v.S == nil {
+		return "", xerrors.Errorf("attribute %s missing: %w", name, ErrMalformedItem)
+	}
+	return *v.S, nil
+}
+
+func (bl Blacklist) int64Attr(item map[string]*dynamodb.AttributeValue, name string) (int64, error) {
+	n, err := bl.numAttr(item, name)
 	if err != nil {
-		log.Fatalf("convert userID %s to int failed", *item["userID"].N)
+		return 0, err
 	}
-	msgID, err := strconv.Atoi(*item["msgID"].N)
+	i, err := strconv.ParseInt(n, 10, 64)
 	if err != nil {
-		log.Fatalf("convert msgID %s to int failed", *item["msgID"].N)
+		return 0, xerrors.Errorf("convert %s %s to int64 failed: %v: %w", name, n, err, ErrMalformedItem)
 	}
-	idx, err := strconv.Atoi(*item["idx"].N)
+	return i, nil
+}
+
+func (bl Blacklist) intAttr(item map[string]*dynamodb.AttributeValue, name string) (int, error) {
+	n, err := bl.numAttr(item, name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(n)
+	if err != nil {
+		return 0, xerrors.Errorf("convert %s %s to int failed: %v: %w", name, n, err, ErrMalformedItem)
+	}
+	return i, nil
+}
+
+// optIntAttr 读取可选的数值属性，旧记录中缺失时返回 0
+func (bl Blacklist) optIntAttr(item map[string]*dynamodb.AttributeValue, name string) (int, error) {
+	if v, ok := item[name]; !ok || v.N == nil {
+		return 0, nil
+	}
+	return bl.intAttr(item, name)
+}
+
+func (bl Blacklist) unmarshal(item map[string]*dynamodb.AttributeValue) (*model.Blacklist, error) {
+	chatID, err := bl.int64Attr(item, "chatID")
 	if err != nil {
-		log.Fatalf("convert idx %s to int failed", *item["idx"].N)
+		return nil, err
+	}
+	userID, err := bl.intAttr(item, "userID")
+	if err != nil {
+		return nil, err
 	}
-	expireAt, err := strconv.ParseInt(*item["expireAt"].N, 10, 64)
+	msgID, err := bl.intAttr(item, "msgID")
 	if err != nil {
-		log.Fatalf("convert expireAt %s to int64 failed", *item["expireAt"].N)
+		return nil, err
+	}
+	// 旧版本以 idx 记录答案下标，这类记录没有 answer，由验证流程重新出题
+	var answer string
+	if _, ok := item["answer"]; ok {
+		if answer, err = bl.strAttr(item, "answer"); err != nil {
+			return nil, err
+		}
+	}
+	expireAt, err := bl.int64Attr(item, "expireAt")
+	if err != nil {
+		return nil, err
+	}
+	msgTemplate, err := bl.strAttr(item, "msgTemplate")
+	if err != nil {
+		return nil, err
+	}
+	userLink, err := bl.strAttr(item, "userLink")
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := bl.optIntAttr(item, "attempts")
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := bl.optIntAttr(item, "maxAttempts")
+	if err != nil {
+		return nil, err
+	}
+	refreshes, err := bl.optIntAttr(item, "refreshes")
+	if err != nil {
+		return nil, err
+	}
+	var refreshAt int64
+	if _, ok := item["refreshAt"]; ok {
+		if refreshAt, err = bl.int64Attr(item, "refreshAt"); err != nil {
+			return nil, err
+		}
+	}
+	var captchaType string
+	if _, ok := item["captchaType"]; ok {
+		if captchaType, err = bl.strAttr(item, "captchaType"); err != nil {
+			return nil, err
+		}
+	}
+	var choices []string
+	if v, ok := item["choices"]; ok {
+		for _, c := range v.L {
+			if c.S == nil {
+				return nil, xerrors.Errorf("attribute choices malformed: %w", ErrMalformedItem)
+			}
+			choices = append(choices, *c.S)
+		}
 	}
 	return &model.Blacklist{
 		ChatID:      chatID,
 		UserID:      userID,
 		MsgID:       msgID,
-		Index:       idx,
+		Answer:      answer,
 		ExpireAt:    time.Unix(0, expireAt),
-		MsgTemplate: *item["msgTemplate"].S,
-		UserLink:    *item["userLink"].S,
+		MsgTemplate: msgTemplate,
+		UserLink:    userLink,
+		Attempts:    attempts,
+		MaxAttempts: maxAttempts,
+		Refreshes:   refreshes,
+		RefreshAt:   nanoToTime(refreshAt),
+		CaptchaType: captchaType,
+		Choices:     choices,
+	}, nil
+}
+
+// timeToNano 与 nanoToTime 以 0 表示零值时间，避免零值时间的 UnixNano 溢出
+func timeToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func nanoToTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, n)
 }
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgIDIndexUnavailable(t *testing.T) {
+	for _, v := range []struct {
+		err  error
+		want bool
+	}{
+		{awserr.New("ValidationException", "The table does not have the specified index: msgID-index", nil), true},
+		{awserr.New("ValidationException", "Cannot read from backfilling global secondary index: msgID-index", nil), true},
+		{awserr.New("ValidationException", "One or more parameter values were invalid", nil), false},
+		{awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found", nil), false},
+		{ErrNotFound, false},
+	} {
+		assert.Equal(t, v.want, msgIDIndexUnavailable(v.err), v.err.Error())
+	}
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+var (
+	boltBlacklistBucket = []byte("blacklist")
+	boltMsgIndexBucket  = []byte("blacklistMsgIdx")
+)
+
+// BoltBlacklist 基于 BoltDB 的本地持久化存储，适用于单机部署
+type BoltBlacklist struct {
+	db   *bolt.DB
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBoltBlacklist 打开 path 处的数据库文件，并每隔 purgeInterval 清理一次过期记录
+func NewBoltBlacklist(path string, purgeInterval time.Duration) (*BoltBlacklist, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, xerrors.Errorf("打开数据库 %s 失败: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltBlacklistBucket, boltMsgIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, xerrors.Errorf("初始化数据库 %s 失败: %w", path, err)
+	}
+	bl := &BoltBlacklist{
+		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go bl.purgeLoop(purgeInterval)
+	return bl, nil
+}
+
+// Close 停止后台清理并关闭数据库
+func (bl *BoltBlacklist) Close() error {
+	close(bl.stop)
+	<-bl.done
+	return bl.db.Close()
+}
+
+func (bl *BoltBlacklist) purgeLoop(interval time.Duration) {
+	defer close(bl.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bl.stop:
+			return
+		case <-ticker.C:
+			if err := bl.purge(time.Now()); err != nil {
+				log.Println("purge expired items failed: ", err)
+			}
+		}
+	}
+}
+
+// purge 删除过期时间早于 now 减去 expireGrace 的记录
+func (bl *BoltBlacklist) purge(now time.Time) error {
+	return bl.db.Update(func(tx *bolt.Tx) error {
+		var expired []model.Blacklist
+		err := tx.Bucket(boltBlacklistBucket).ForEach(func(k, v []byte) error {
+			item := model.Blacklist{}
+			if err := json.Unmarshal(v, &item); err != nil {
+				log.Printf("解码记录 %s 失败: %+v", k, err)
+				return nil
+			}
+			if item.ExpireAt.Add(expireGrace).Before(now) {
+				expired = append(expired, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, v := range expired {
+			if err := bl.delete(tx, v.ChatID, v.UserID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bl *BoltBlacklist) userKey(chatID int64, userID int) []byte {
+	return []byte(strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(userID))
+}
+
+func (bl *BoltBlacklist) msgKey(chatID int64, msgID int) []byte {
+	return []byte(strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(msgID))
+}
+
+func (bl *BoltBlacklist) get(tx *bolt.Tx, key []byte) (*model.Blacklist, error) {
+	v := tx.Bucket(boltBlacklistBucket).Get(key)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	item := &model.Blacklist{}
+	if err := json.Unmarshal(v, item); err != nil {
+		return nil, xerrors.Errorf("解码记录 %s 失败: %v: %w", key, err, ErrMalformedItem)
+	}
+	if item.ExpireAt.Add(expireGrace).Before(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+func (bl *BoltBlacklist) put(tx *bolt.Tx, item model.Blacklist) error {
+	v, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltBlacklistBucket).Put(bl.userKey(item.ChatID, item.UserID), v)
+}
+
+func (bl *BoltBlacklist) delete(tx *bolt.Tx, chatID int64, userID int) error {
+	key := bl.userKey(chatID, userID)
+	items := tx.Bucket(boltBlacklistBucket)
+	v := items.Get(key)
+	if v == nil {
+		return nil
+	}
+	item := model.Blacklist{}
+	if err := json.Unmarshal(v, &item); err != nil {
+		return xerrors.Errorf("解码记录 %s 失败: %v: %w", key, err, ErrMalformedItem)
+	}
+	msgs := tx.Bucket(boltMsgIndexBucket)
+	mKey := bl.msgKey(chatID, item.MsgID)
+	if string(msgs.Get(mKey)) == string(key) {
+		if err := msgs.Delete(mKey); err != nil {
+			return err
+		}
+	}
+	return items.Delete(key)
+}
+
+func (bl *BoltBlacklist) GetItem(ctx context.Context, chatID int64, userID int) (*model.Blacklist, error) {
+	var item *model.Blacklist
+	err := bl.db.View(func(tx *bolt.Tx) error {
+		var err error
+		item, err = bl.get(tx, bl.userKey(chatID, userID))
+		return err
+	})
+	return item, err
+}
+
+func (bl *BoltBlacklist) UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error {
+	err := bl.db.Update(func(tx *bolt.Tx) error {
+		item, err := bl.get(tx, bl.userKey(chatID, userID))
+		if err != nil {
+			return err
+		}
+		if item.Refreshes != refreshes {
+			return ErrNotFound
+		}
+		item.Answer = answer
+		item.Choices = choices
+		item.Refreshes++
+		item.RefreshAt = refreshAt
+		return bl.put(tx, *item)
+	})
+	if err != nil && err != ErrNotFound {
+		return xerrors.Errorf("update item failed: %w", err)
+	}
+	return err
+}
+
+func (bl *BoltBlacklist) IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error) {
+	var attempts int
+	err := bl.db.Update(func(tx *bolt.Tx) error {
+		item, err := bl.get(tx, bl.userKey(chatID, userID))
+		if err != nil {
+			return err
+		}
+		if item.MsgID != msgID {
+			return ErrNotFound
+		}
+		item.Attempts++
+		attempts = item.Attempts
+		return bl.put(tx, *item)
+	})
+	if err != nil && err != ErrNotFound {
+		return 0, xerrors.Errorf("incr attempts failed: %w", err)
+	}
+	return attempts, err
+}
+
+func (bl *BoltBlacklist) DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error {
+	err := bl.db.Update(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBlacklistBucket).Get(bl.userKey(chatID, userID))
+		if v == nil {
+			return ErrNotFound
+		}
+		item := model.Blacklist{}
+		if err := json.Unmarshal(v, &item); err != nil {
+			return xerrors.Errorf("解码记录失败: %v: %w", err, ErrMalformedItem)
+		}
+		if item.MsgID != msgID {
+			return ErrNotFound
+		}
+		return bl.delete(tx, chatID, userID)
+	})
+	if err != nil && err != ErrNotFound {
+		return xerrors.Errorf("delete item failed: %w", err)
+	}
+	return err
+}
+
+func (bl *BoltBlacklist) CreateItem(ctx context.Context, item model.Blacklist) error {
+	err := bl.db.Update(func(tx *bolt.Tx) error {
+		if err := bl.delete(tx, item.ChatID, item.UserID); err != nil {
+			return err
+		}
+		if err := bl.put(tx, item); err != nil {
+			return err
+		}
+		return tx.Bucket(boltMsgIndexBucket).Put(
+			bl.msgKey(item.ChatID, item.MsgID),
+			bl.userKey(item.ChatID, item.UserID),
+		)
+	})
+	if err != nil {
+		return xerrors.Errorf("create item failed: %w", err)
+	}
+	return nil
+}
+
+func (bl *BoltBlacklist) GetItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error) {
+	var item *model.Blacklist
+	err := bl.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(boltMsgIndexBucket).Get(bl.msgKey(chatID, msgID))
+		if key == nil {
+			return ErrNotFound
+		}
+		var err error
+		item, err = bl.get(tx, key)
+		return err
+	})
+	return item, err
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltBlacklist(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "drei")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blacklist.db")
+
+	bl, err := NewBoltBlacklist(path, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{
+		ChatID:   1,
+		UserID:   1,
+		MsgID:    2,
+		Answer:   "画蛇添足",
+		ExpireAt: time.Now().Add(time.Minute),
+		UserLink: "UserLink",
+	}))
+	assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 1, UserID: 2, MsgID: 4, ExpireAt: time.Now().Add(-time.Hour)}))
+
+	t.Run("重启后读取记录", func(t *testing.T) {
+		assert.NoError(t, bl.Close())
+		bl, err = NewBoltBlacklist(path, time.Hour)
+		assert.NoError(t, err)
+
+		item, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "画蛇添足", item.Answer)
+		assert.Equal(t, "UserLink", item.UserLink)
+
+		item, err = bl.GetItemByMsgID(ctx, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, item.UserID)
+	})
+
+	t.Run("过期记录不可读取", func(t *testing.T) {
+		_, err := bl.GetItem(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, 1, 4)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("清理过期记录", func(t *testing.T) {
+		assert.NoError(t, bl.purge(time.Now()))
+		_, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+
+		assert.NoError(t, bl.purge(time.Now().Add(time.Hour)))
+		_, err = bl.GetItem(ctx, 1, 1)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("更新及删除记录", func(t *testing.T) {
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 1, UserID: 3, MsgID: 5, ExpireAt: time.Now().Add(time.Minute)}))
+		assert.NoError(t, bl.UpdateAnswer(ctx, 1, 3, "一心一意", nil, 0, time.Now()))
+		item, err := bl.GetItemByMsgID(ctx, 1, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, "一心一意", item.Answer)
+
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, 1, 3, 4))
+		assert.NoError(t, bl.DeleteItem(ctx, 1, 3, 5))
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, 1, 3, 5))
+		_, err = bl.GetItem(ctx, 1, 3)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, 1, 5)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	assert.NoError(t, bl.Close())
+}
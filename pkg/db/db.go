@@ -2,18 +2,49 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/jqs7/drei/pkg/model"
 	"golang.org/x/xerrors"
 )
 
-var ErrNotFound = xerrors.New("Record Not Found")
+var (
+	ErrNotFound      = xerrors.New("Record Not Found")
+	ErrMalformedItem = xerrors.New("Malformed Record")
+)
 
-//go:generate go run github.com/golang/mock/mockgen -source=db.go -package=db -destination=mock.go IBlacklist
+//go:generate go run github.com/golang/mock/mockgen -source=db.go -package=db -destination=mock.go IBlacklist,ISettings,IAudit,IStats
 type IBlacklist interface {
 	GetItem(ctx context.Context, chatID int64, userID int) (*model.Blacklist, error)
-	UpdateIdx(ctx context.Context, chatID int64, userID, idx int)
-	DeleteItem(ctx context.Context, chatID int64, userID int)
-	CreateItem(ctx context.Context, item model.Blacklist)
+	// UpdateAnswer 更换验证码答案令牌及选项，并将刷新次数加一、刷新时间记为 refreshAt；
+	// 仅当记录的刷新次数仍为 refreshes 时更新，以免并发刷新绕过频率限制，否则返回 ErrNotFound
+	UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error
+	// DeleteItem 仅当记录仍属于 msgID 对应的验证码消息时删除，否则返回 ErrNotFound，
+	// 并发处理同一条验证记录时，只有删除成功的一方可以继续执行通过、踢出等操作
+	DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error
+	// IncrAttempts 将 msgID 对应验证码的错误次数加一并返回最新次数，记录不存在或 msgID 不匹配时返回 ErrNotFound
+	IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error)
+	CreateItem(ctx context.Context, item model.Blacklist) error
 	GetItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error)
 }
+
+type ISettings interface {
+	// GetSettings 在群组未保存过设置时返回 model.DefaultChatSettings
+	GetSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error)
+	PutSettings(ctx context.Context, settings model.ChatSettings) error
+}
+
+// IAudit 为只追加的验证审计日志
+type IAudit interface {
+	AppendEvent(ctx context.Context, event model.AuditEvent) error
+	// ListEvents 按时间倒序返回用户最近的 limit 条记录
+	ListEvents(ctx context.Context, chatID int64, userID int, limit int) ([]model.AuditEvent, error)
+}
+
+// IStats 为按天汇总的群组验证统计
+type IStats interface {
+	// IncrStat 将群组在 day 当天的 field 统计项加一
+	IncrStat(ctx context.Context, chatID int64, day int, field string) error
+	// ListStats 返回群组自 sinceDay 起（含当天）每天的统计，未产生统计的日期不返回
+	ListStats(ctx context.Context, chatID int64, sinceDay int) ([]model.DailyStats, error)
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+// expireGrace 为记录过期后仍可读取的时长，
+// 以保证倒计时任务在过期时刻仍能取到记录并将用户移出群组
+const expireGrace = time.Minute
+
+type userKey struct {
+	chatID int64
+	userID int
+}
+
+type msgKey struct {
+	chatID int64
+	msgID  int
+}
+
+type MemoryBlacklist struct {
+	mu    sync.RWMutex
+	items map[userKey]model.Blacklist
+	msgs  map[msgKey]int
+	now   func() time.Time
+}
+
+func NewMemoryBlacklist() IBlacklist {
+	return &MemoryBlacklist{
+		items: map[userKey]model.Blacklist{},
+		msgs:  map[msgKey]int{},
+		now:   time.Now,
+	}
+}
+
+func (bl *MemoryBlacklist) expired(item model.Blacklist) bool {
+	return item.ExpireAt.Add(expireGrace).Before(bl.now())
+}
+
+func (bl *MemoryBlacklist) GetItem(ctx context.Context, chatID int64, userID int) (*model.Blacklist, error) {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+	item, ok := bl.items[userKey{chatID: chatID, userID: userID}]
+	if !ok || bl.expired(item) {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (bl *MemoryBlacklist) UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	key := userKey{chatID: chatID, userID: userID}
+	item, ok := bl.items[key]
+	if !ok || bl.expired(item) || item.Refreshes != refreshes {
+		return ErrNotFound
+	}
+	item.Answer = answer
+	item.Choices = choices
+	item.Refreshes++
+	item.RefreshAt = refreshAt
+	bl.items[key] = item
+	return nil
+}
+
+func (bl *MemoryBlacklist) DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	key := userKey{chatID: chatID, userID: userID}
+	item, ok := bl.items[key]
+	if !ok || item.MsgID != msgID {
+		return ErrNotFound
+	}
+	bl.deleteLocked(key)
+	return nil
+}
+
+func (bl *MemoryBlacklist) IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	key := userKey{chatID: chatID, userID: userID}
+	item, ok := bl.items[key]
+	if !ok || item.MsgID != msgID || bl.expired(item) {
+		return 0, ErrNotFound
+	}
+	item.Attempts++
+	bl.items[key] = item
+	return item.Attempts, nil
+}
+
+func (bl *MemoryBlacklist) deleteLocked(key userKey) {
+	item, ok := bl.items[key]
+	if !ok {
+		return
+	}
+	delete(bl.items, key)
+	mKey := msgKey{chatID: item.ChatID, msgID: item.MsgID}
+	if bl.msgs[mKey] == item.UserID {
+		delete(bl.msgs, mKey)
+	}
+}
+
+func (bl *MemoryBlacklist) CreateItem(ctx context.Context, item model.Blacklist) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.purgeLocked()
+	key := userKey{chatID: item.ChatID, userID: item.UserID}
+	bl.deleteLocked(key)
+	bl.items[key] = item
+	bl.msgs[msgKey{chatID: item.ChatID, msgID: item.MsgID}] = item.UserID
+	return nil
+}
+
+// purgeLocked 清理已过期的记录，效果等同于 DynamoDB 的 TTL
+func (bl *MemoryBlacklist) purgeLocked() {
+	for k, v := range bl.items {
+		if bl.expired(v) {
+			bl.deleteLocked(k)
+		}
+	}
+}
+
+func (bl *MemoryBlacklist) GetItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error) {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+	userID, ok := bl.msgs[msgKey{chatID: chatID, msgID: msgID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	item, ok := bl.items[userKey{chatID: chatID, userID: userID}]
+	if !ok || bl.expired(item) {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+type MemorySettings struct {
+	mu       sync.RWMutex
+	settings map[int64]model.ChatSettings
+}
+
+func NewMemorySettings() ISettings {
+	return &MemorySettings{
+		settings: map[int64]model.ChatSettings{},
+	}
+}
+
+func (s *MemorySettings) GetSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	settings, ok := s.settings[chatID]
+	if !ok {
+		settings = model.DefaultChatSettings(chatID)
+	}
+	return &settings, nil
+}
+
+func (s *MemorySettings) PutSettings(ctx context.Context, settings model.ChatSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[settings.ChatID] = settings
+	return nil
+}
+
+type MemoryAudit struct {
+	mu     sync.RWMutex
+	events map[userKey][]model.AuditEvent
+}
+
+func NewMemoryAudit() IAudit {
+	return &MemoryAudit{
+		events: map[userKey][]model.AuditEvent{},
+	}
+}
+
+func (a *MemoryAudit) AppendEvent(ctx context.Context, event model.AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := userKey{chatID: event.ChatID, userID: event.UserID}
+	a.events[key] = append(a.events[key], event)
+	return nil
+}
+
+func (a *MemoryAudit) ListEvents(ctx context.Context, chatID int64, userID int, limit int) ([]model.AuditEvent, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	events := a.events[userKey{chatID: chatID, userID: userID}]
+	rst := make([]model.AuditEvent, 0, limit)
+	for i := len(events) - 1; i >= 0 && len(rst) < limit; i-- {
+		rst = append(rst, events[i])
+	}
+	return rst, nil
+}
+
+type statsKey struct {
+	chatID int64
+	day    int
+}
+
+type MemoryStats struct {
+	mu    sync.RWMutex
+	stats map[statsKey]model.DailyStats
+}
+
+func NewMemoryStats() IStats {
+	return &MemoryStats{
+		stats: map[statsKey]model.DailyStats{},
+	}
+}
+
+func (s *MemoryStats) IncrStat(ctx context.Context, chatID int64, day int, field string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := statsKey{chatID: chatID, day: day}
+	stats := s.stats[key]
+	stats.ChatID, stats.Day = chatID, day
+	switch field {
+	case model.StatChallenged:
+		stats.Challenged++
+	case model.StatPassed:
+		stats.Passed++
+	case model.StatTimedOut:
+		stats.TimedOut++
+	case model.StatKicked:
+		stats.Kicked++
+	case model.StatLockedOut:
+		stats.LockedOut++
+	default:
+		return xerrors.Errorf("unknown stat %s", field)
+	}
+	s.stats[key] = stats
+	return nil
+}
+
+func (s *MemoryStats) ListStats(ctx context.Context, chatID int64, sinceDay int) ([]model.DailyStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rst []model.DailyStats
+	for k, v := range s.stats {
+		if k.chatID == chatID && k.day >= sinceDay {
+			rst = append(rst, v)
+		}
+	}
+	sort.Slice(rst, func(i, j int) bool {
+		return rst[i].Day < rst[j].Day
+	})
+	return rst, nil
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBlacklist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newBlacklist := func() *MemoryBlacklist {
+		bl := NewMemoryBlacklist().(*MemoryBlacklist)
+		bl.now = func() time.Time { return now }
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{
+			ChatID:   1,
+			UserID:   1,
+			MsgID:    2,
+			Answer:   "画蛇添足",
+			ExpireAt: now.Add(time.Minute),
+		}))
+		return bl
+	}
+
+	t.Run("读取记录", func(t *testing.T) {
+		bl := newBlacklist()
+		item, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, item.MsgID)
+		assert.Equal(t, "画蛇添足", item.Answer)
+
+		_, err = bl.GetItem(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("根据消息 ID 读取记录", func(t *testing.T) {
+		bl := newBlacklist()
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 1, UserID: 2, MsgID: 3, ExpireAt: now.Add(time.Minute)}))
+
+		item, err := bl.GetItemByMsgID(ctx, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, item.UserID)
+
+		item, err = bl.GetItemByMsgID(ctx, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, item.UserID)
+
+		_, err = bl.GetItemByMsgID(ctx, 2, 2)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("更新验证码", func(t *testing.T) {
+		bl := newBlacklist()
+		assert.NoError(t, bl.UpdateAnswer(ctx, 1, 1, "一心一意", nil, 0, now))
+		item, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "一心一意", item.Answer)
+		assert.Equal(t, 1, item.Refreshes)
+		assert.Equal(t, now, item.RefreshAt)
+
+		assert.Equal(t, ErrNotFound, bl.UpdateAnswer(ctx, 1, 1, "三心二意", nil, 0, now), "刷新次数不匹配时不应更新")
+		assert.Equal(t, ErrNotFound, bl.UpdateAnswer(ctx, 1, 2, "一心一意", nil, 0, now))
+	})
+
+	t.Run("累加错误次数", func(t *testing.T) {
+		bl := newBlacklist()
+		attempts, err := bl.IncrAttempts(ctx, 1, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+		attempts, err = bl.IncrAttempts(ctx, 1, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+
+		_, err = bl.IncrAttempts(ctx, 1, 1, 3)
+		assert.Equal(t, ErrNotFound, err, "旧验证码不应累加新记录")
+		item, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, item.Attempts)
+	})
+
+	t.Run("删除记录", func(t *testing.T) {
+		bl := newBlacklist()
+		assert.NoError(t, bl.DeleteItem(ctx, 1, 1, 2))
+		_, err := bl.GetItem(ctx, 1, 1)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("消息 ID 不匹配时不删除记录", func(t *testing.T) {
+		bl := newBlacklist()
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, 1, 1, 3))
+		_, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, 1, 2, 2))
+	})
+
+	t.Run("并发删除仅一方成功", func(t *testing.T) {
+		bl := newBlacklist()
+		var (
+			wg  sync.WaitGroup
+			mu  sync.Mutex
+			won int
+		)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if bl.DeleteItem(ctx, 1, 1, 2) == nil {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, won)
+	})
+
+	t.Run("重新进群覆盖旧记录", func(t *testing.T) {
+		bl := newBlacklist()
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 1, UserID: 1, MsgID: 5, ExpireAt: now.Add(time.Minute)}))
+		_, err := bl.GetItemByMsgID(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+		item, err := bl.GetItemByMsgID(ctx, 1, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, item.UserID)
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, 1, 1, 2), "旧验证码不应删除新记录")
+	})
+
+	t.Run("记录过期", func(t *testing.T) {
+		bl := newBlacklist()
+		now = now.Add(time.Minute)
+		_, err := bl.GetItem(ctx, 1, 1)
+		assert.NoError(t, err, "过期时刻仍可读取")
+
+		now = now.Add(expireGrace + time.Second)
+		_, err = bl.GetItem(ctx, 1, 1)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, 1, 2)
+		assert.Equal(t, ErrNotFound, err)
+
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 1, UserID: 2, MsgID: 3, ExpireAt: now.Add(time.Minute)}))
+		assert.Len(t, bl.items, 1)
+		assert.Len(t, bl.msgs, 1)
+	})
+
+	t.Run("并发读写", func(t *testing.T) {
+		bl := newBlacklist()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: 2, UserID: i, MsgID: i, ExpireAt: now.Add(time.Minute)}))
+				assert.NoError(t, bl.UpdateAnswer(ctx, 2, i, strconv.Itoa(i), nil, 0, now))
+				_, _ = bl.GetItemByMsgID(ctx, 2, i)
+				assert.NoError(t, bl.DeleteItem(ctx, 2, i, i))
+			}(i)
+		}
+		wg.Wait()
+		_, err := bl.GetItem(ctx, 2, 0)
+		assert.Equal(t, ErrNotFound, err)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	model "github.com/jqs7/drei/pkg/model"
 	reflect "reflect"
+	time "time"
 )
 
 // MockIBlacklist is a mock of IBlacklist interface
@@ -49,34 +50,55 @@ func (mr *MockIBlacklistMockRecorder) GetItem(ctx, chatID, userID interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockIBlacklist)(nil).GetItem), ctx, chatID, userID)
 }
 
-// UpdateIdx mocks base method
-func (m *MockIBlacklist) UpdateIdx(ctx context.Context, chatID int64, userID, idx int) {
+// UpdateAnswer mocks base method
+func (m *MockIBlacklist) UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "UpdateIdx", ctx, chatID, userID, idx)
+	ret := m.ctrl.Call(m, "UpdateAnswer", ctx, chatID, userID, answer, choices, refreshes, refreshAt)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// UpdateIdx indicates an expected call of UpdateIdx
-func (mr *MockIBlacklistMockRecorder) UpdateIdx(ctx, chatID, userID, idx interface{}) *gomock.Call {
+// UpdateAnswer indicates an expected call of UpdateAnswer
+func (mr *MockIBlacklistMockRecorder) UpdateAnswer(ctx, chatID, userID, answer, choices, refreshes, refreshAt interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdx", reflect.TypeOf((*MockIBlacklist)(nil).UpdateIdx), ctx, chatID, userID, idx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAnswer", reflect.TypeOf((*MockIBlacklist)(nil).UpdateAnswer), ctx, chatID, userID, answer, choices, refreshes, refreshAt)
 }
 
 // DeleteItem mocks base method
-func (m *MockIBlacklist) DeleteItem(ctx context.Context, chatID int64, userID int) {
+func (m *MockIBlacklist) DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "DeleteItem", ctx, chatID, userID)
+	ret := m.ctrl.Call(m, "DeleteItem", ctx, chatID, userID, msgID)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // DeleteItem indicates an expected call of DeleteItem
-func (mr *MockIBlacklistMockRecorder) DeleteItem(ctx, chatID, userID interface{}) *gomock.Call {
+func (mr *MockIBlacklistMockRecorder) DeleteItem(ctx, chatID, userID, msgID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteItem", reflect.TypeOf((*MockIBlacklist)(nil).DeleteItem), ctx, chatID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteItem", reflect.TypeOf((*MockIBlacklist)(nil).DeleteItem), ctx, chatID, userID, msgID)
+}
+
+// IncrAttempts mocks base method
+func (m *MockIBlacklist) IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrAttempts", ctx, chatID, userID, msgID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrAttempts indicates an expected call of IncrAttempts
+func (mr *MockIBlacklistMockRecorder) IncrAttempts(ctx, chatID, userID, msgID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrAttempts", reflect.TypeOf((*MockIBlacklist)(nil).IncrAttempts), ctx, chatID, userID, msgID)
 }
 
 // CreateItem mocks base method
-func (m *MockIBlacklist) CreateItem(ctx context.Context, item model.Blacklist) {
+func (m *MockIBlacklist) CreateItem(ctx context.Context, item model.Blacklist) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "CreateItem", ctx, item)
+	ret := m.ctrl.Call(m, "CreateItem", ctx, item)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // CreateItem indicates an expected call of CreateItem
@@ -99,3 +121,159 @@ func (mr *MockIBlacklistMockRecorder) GetItemByMsgID(ctx, chatID, msgID interfac
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemByMsgID", reflect.TypeOf((*MockIBlacklist)(nil).GetItemByMsgID), ctx, chatID, msgID)
 }
+
+// MockISettings is a mock of ISettings interface
+type MockISettings struct {
+	ctrl     *gomock.Controller
+	recorder *MockISettingsMockRecorder
+}
+
+// MockISettingsMockRecorder is the mock recorder for MockISettings
+type MockISettingsMockRecorder struct {
+	mock *MockISettings
+}
+
+// NewMockISettings creates a new mock instance
+func NewMockISettings(ctrl *gomock.Controller) *MockISettings {
+	mock := &MockISettings{ctrl: ctrl}
+	mock.recorder = &MockISettingsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockISettings) EXPECT() *MockISettingsMockRecorder {
+	return m.recorder
+}
+
+// GetSettings mocks base method
+func (m *MockISettings) GetSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSettings", ctx, chatID)
+	ret0, _ := ret[0].(*model.ChatSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSettings indicates an expected call of GetSettings
+func (mr *MockISettingsMockRecorder) GetSettings(ctx, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSettings", reflect.TypeOf((*MockISettings)(nil).GetSettings), ctx, chatID)
+}
+
+// PutSettings mocks base method
+func (m *MockISettings) PutSettings(ctx context.Context, settings model.ChatSettings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutSettings", ctx, settings)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutSettings indicates an expected call of PutSettings
+func (mr *MockISettingsMockRecorder) PutSettings(ctx, settings interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutSettings", reflect.TypeOf((*MockISettings)(nil).PutSettings), ctx, settings)
+}
+
+// MockIAudit is a mock of IAudit interface
+type MockIAudit struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAuditMockRecorder
+}
+
+// MockIAuditMockRecorder is the mock recorder for MockIAudit
+type MockIAuditMockRecorder struct {
+	mock *MockIAudit
+}
+
+// NewMockIAudit creates a new mock instance
+func NewMockIAudit(ctrl *gomock.Controller) *MockIAudit {
+	mock := &MockIAudit{ctrl: ctrl}
+	mock.recorder = &MockIAuditMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockIAudit) EXPECT() *MockIAuditMockRecorder {
+	return m.recorder
+}
+
+// AppendEvent mocks base method
+func (m *MockIAudit) AppendEvent(ctx context.Context, event model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendEvent indicates an expected call of AppendEvent
+func (mr *MockIAuditMockRecorder) AppendEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendEvent", reflect.TypeOf((*MockIAudit)(nil).AppendEvent), ctx, event)
+}
+
+// ListEvents mocks base method
+func (m *MockIAudit) ListEvents(ctx context.Context, chatID int64, userID, limit int) ([]model.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvents", ctx, chatID, userID, limit)
+	ret0, _ := ret[0].([]model.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvents indicates an expected call of ListEvents
+func (mr *MockIAuditMockRecorder) ListEvents(ctx, chatID, userID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockIAudit)(nil).ListEvents), ctx, chatID, userID, limit)
+}
+
+// MockIStats is a mock of IStats interface
+type MockIStats struct {
+	ctrl     *gomock.Controller
+	recorder *MockIStatsMockRecorder
+}
+
+// MockIStatsMockRecorder is the mock recorder for MockIStats
+type MockIStatsMockRecorder struct {
+	mock *MockIStats
+}
+
+// NewMockIStats creates a new mock instance
+func NewMockIStats(ctrl *gomock.Controller) *MockIStats {
+	mock := &MockIStats{ctrl: ctrl}
+	mock.recorder = &MockIStatsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockIStats) EXPECT() *MockIStatsMockRecorder {
+	return m.recorder
+}
+
+// IncrStat mocks base method
+func (m *MockIStats) IncrStat(ctx context.Context, chatID int64, day int, field string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrStat", ctx, chatID, day, field)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrStat indicates an expected call of IncrStat
+func (mr *MockIStatsMockRecorder) IncrStat(ctx, chatID, day, field interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrStat", reflect.TypeOf((*MockIStats)(nil).IncrStat), ctx, chatID, day, field)
+}
+
+// ListStats mocks base method
+func (m *MockIStats) ListStats(ctx context.Context, chatID int64, sinceDay int) ([]model.DailyStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStats", ctx, chatID, sinceDay)
+	ret0, _ := ret[0].([]model.DailyStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStats indicates an expected call of ListStats
+func (mr *MockIStatsMockRecorder) ListStats(ctx, chatID, sinceDay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStats", reflect.TypeOf((*MockIStats)(nil).ListStats), ctx, chatID, sinceDay)
+}
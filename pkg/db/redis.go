@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+// redisCreateScript 写入新记录，并清除同一用户旧记录遗留的消息索引
+// KEYS[1]: 记录 key, KEYS[2]: 消息索引 key
+// ARGV[1]: 过期时间戳（毫秒）, ARGV[2]: 旧消息索引 key 前缀, ARGV[3...]: 记录字段
+var redisCreateScript = redis.NewScript(`
+local oldMsgID = redis.call('HGET', KEYS[1], 'msgID')
+if oldMsgID then
+	local oldMsgKey = ARGV[2] .. oldMsgID
+	if redis.call('GET', oldMsgKey) == KEYS[1] then
+		redis.call('DEL', oldMsgKey)
+	end
+end
+redis.call('DEL', KEYS[1])
+redis.call('HMSET', KEYS[1], unpack(ARGV, 3))
+redis.call('PEXPIREAT', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], KEYS[1])
+redis.call('PEXPIREAT', KEYS[2], ARGV[1])
+return 1
+`)
+
+// redisUpdateScript 仅在记录存在且刷新次数匹配时更换验证码，避免创建没有过期时间的残缺记录
+// KEYS[1]: 记录 key, ARGV[1]: 答案令牌, ARGV[2]: 当前刷新次数, ARGV[3]: 刷新时间, ARGV[4]: 选项
+var redisUpdateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local refreshes = tonumber(redis.call('HGET', KEYS[1], 'refreshes') or '0')
+if refreshes ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HMSET', KEYS[1], 'answer', ARGV[1], 'refreshes', refreshes + 1, 'refreshAt', ARGV[3], 'choices', ARGV[4])
+return 1
+`)
+
+// redisIncrAttemptsScript 在记录的 msgID 匹配时将错误次数加一，不匹配时返回 -1
+// KEYS[1]: 记录 key, ARGV[1]: msgID
+var redisIncrAttemptsScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'msgID') ~= ARGV[1] then
+	return -1
+end
+return redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+`)
+
+// redisDeleteScript 在记录的 msgID 匹配时删除记录及其消息索引
+// KEYS[1]: 记录 key, ARGV[1]: 消息索引 key 前缀, ARGV[2]: msgID
+var redisDeleteScript = redis.NewScript(`
+local msgID = redis.call('HGET', KEYS[1], 'msgID')
+if msgID ~= ARGV[2] then
+	return 0
+end
+local msgKey = ARGV[1] .. msgID
+if redis.call('GET', msgKey) == KEYS[1] then
+	redis.call('DEL', msgKey)
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// redisChoiceSep 为选项之间的分隔符，选项均为成语或数字，不会包含该字符
+const redisChoiceSep = "\n"
+
+// RedisBlacklist 基于 Redis 的存储，记录随 ExpireAt 由 Redis 自动过期，适用于多实例部署
+type RedisBlacklist struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisBlacklist(client *redis.Client, prefix string) IBlacklist {
+	return &RedisBlacklist{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (bl RedisBlacklist) userKey(chatID int64, userID int) string {
+	return bl.prefix + "blacklist:" + strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(userID)
+}
+
+func (bl RedisBlacklist) msgKeyPrefix(chatID int64) string {
+	return bl.prefix + "blacklistMsg:" + strconv.FormatInt(chatID, 10) + ":"
+}
+
+func (bl RedisBlacklist) GetItem(ctx context.Context, chatID int64, userID int) (*model.Blacklist, error) {
+	return bl.get(ctx, bl.userKey(chatID, userID))
+}
+
+func (bl RedisBlacklist) get(ctx context.Context, key string) (*model.Blacklist, error) {
+	result, err := bl.client.WithContext(ctx).HGetAll(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+	return bl.unmarshal(result)
+}
+
+func (bl RedisBlacklist) UpdateAnswer(ctx context.Context, chatID int64, userID int, answer string, choices []string, refreshes int, refreshAt time.Time) error {
+	updated, err := redisUpdateScript.Run(bl.client.WithContext(ctx),
+		[]string{bl.userKey(chatID, userID)}, answer, refreshes, timeToNano(refreshAt), strings.Join(choices, redisChoiceSep),
+	).Int()
+	if err != nil {
+		return xerrors.Errorf("update item failed: %w", err)
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (bl RedisBlacklist) IncrAttempts(ctx context.Context, chatID int64, userID, msgID int) (int, error) {
+	attempts, err := redisIncrAttemptsScript.Run(bl.client.WithContext(ctx),
+		[]string{bl.userKey(chatID, userID)}, msgID,
+	).Int()
+	if err != nil {
+		return 0, xerrors.Errorf("incr attempts failed: %w", err)
+	}
+	if attempts < 0 {
+		return 0, ErrNotFound
+	}
+	return attempts, nil
+}
+
+func (bl RedisBlacklist) DeleteItem(ctx context.Context, chatID int64, userID, msgID int) error {
+	deleted, err := redisDeleteScript.Run(bl.client.WithContext(ctx),
+		[]string{bl.userKey(chatID, userID)}, bl.msgKeyPrefix(chatID), msgID,
+	).Int()
+	if err != nil {
+		return xerrors.Errorf("delete item failed: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (bl RedisBlacklist) CreateItem(ctx context.Context, item model.Blacklist) error {
+	args := []interface{}{
+		item.ExpireAt.Add(expireGrace).UnixNano() / int64(time.Millisecond),
+		bl.msgKeyPrefix(item.ChatID),
+	}
+	args = append(args, bl.marshalItem(item)...)
+	err := redisCreateScript.Run(bl.client.WithContext(ctx),
+		[]string{
+			bl.userKey(item.ChatID, item.UserID),
+			bl.msgKeyPrefix(item.ChatID) + strconv.Itoa(item.MsgID),
+		}, args...,
+	).Err()
+	if err != nil {
+		return xerrors.Errorf("create item failed: %w", err)
+	}
+	return nil
+}
+
+func (bl RedisBlacklist) GetItemByMsgID(ctx context.Context, chatID int64, msgID int) (*model.Blacklist, error) {
+	key, err := bl.client.WithContext(ctx).Get(bl.msgKeyPrefix(chatID) + strconv.Itoa(msgID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return bl.get(ctx, key)
+}
+
+func (bl RedisBlacklist) marshalItem(item model.Blacklist) []interface{} {
+	return []interface{}{
+		"chatID", item.ChatID,
+		"userID", item.UserID,
+		"msgID", item.MsgID,
+		"answer", item.Answer,
+		"expireAt", item.ExpireAt.UnixNano(),
+		"userLink", item.UserLink,
+		"msgTemplate", item.MsgTemplate,
+		"attempts", item.Attempts,
+		"maxAttempts", item.MaxAttempts,
+		"refreshes", item.Refreshes,
+		"refreshAt", timeToNano(item.RefreshAt),
+		"captchaType", item.CaptchaType,
+		"choices", strings.Join(item.Choices, redisChoiceSep),
+	}
+}
+
+func (bl RedisBlacklist) unmarshal(item map[string]string) (*model.Blacklist, error) {
+	chatID, err := strconv.ParseInt(item["chatID"], 10, 64)
+	if err != nil {
+		return nil, xerrors.Errorf("convert chatID %s to int64 failed: %v: %w", item["chatID"], err, ErrMalformedItem)
+	}
+	userID, err := strconv.Atoi(item["userID"])
+	if err != nil {
+		return nil, xerrors.Errorf("convert userID %s to int failed: %v: %w", item["userID"], err, ErrMalformedItem)
+	}
+	msgID, err := strconv.Atoi(item["msgID"])
+	if err != nil {
+		return nil, xerrors.Errorf("convert msgID %s to int failed: %v: %w", item["msgID"], err, ErrMalformedItem)
+	}
+	expireAt, err := strconv.ParseInt(item["expireAt"], 10, 64)
+	if err != nil {
+		return nil, xerrors.Errorf("convert expireAt %s to int64 failed: %v: %w", item["expireAt"], err, ErrMalformedItem)
+	}
+	// 缺少的计数字段视为 0
+	optInt := func(name string) (int, error) {
+		v, ok := item[name]
+		if !ok {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, xerrors.Errorf("convert %s %s to int failed: %v: %w", name, v, err, ErrMalformedItem)
+		}
+		return i, nil
+	}
+	attempts, err := optInt("attempts")
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := optInt("maxAttempts")
+	if err != nil {
+		return nil, err
+	}
+	refreshes, err := optInt("refreshes")
+	if err != nil {
+		return nil, err
+	}
+	var choices []string
+	if v := item["choices"]; v != "" {
+		choices = strings.Split(v, redisChoiceSep)
+	}
+	var refreshAt int64
+	if v, ok := item["refreshAt"]; ok {
+		if refreshAt, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, xerrors.Errorf("convert refreshAt %s to int64 failed: %v: %w", v, err, ErrMalformedItem)
+		}
+	}
+	return &model.Blacklist{
+		ChatID:      chatID,
+		UserID:      userID,
+		MsgID:       msgID,
+		Answer:      item["answer"],
+		ExpireAt:    time.Unix(0, expireAt),
+		MsgTemplate: item["msgTemplate"],
+		UserLink:    item["userLink"],
+		Attempts:    attempts,
+		MaxAttempts: maxAttempts,
+		Refreshes:   refreshes,
+		RefreshAt:   nanoToTime(refreshAt),
+		CaptchaType: item["captchaType"],
+		Choices:     choices,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// 设置 REDIS_ADDR 环境变量即可使用本地 redis-server 测试，否则使用 miniredis
+func newTestRedis(t *testing.T) (client *redis.Client, fastForward func(time.Duration), closer func()) {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return redis.NewClient(&redis.Options{Addr: addr}), nil, func() {}
+	}
+	s, err := miniredis.Run()
+	assert.NoError(t, err)
+	return redis.NewClient(&redis.Options{Addr: s.Addr()}), s.FastForward, s.Close
+}
+
+func TestRedisBlacklist(t *testing.T) {
+	ctx := context.Background()
+	client, fastForward, closer := newTestRedis(t)
+	defer closer()
+	bl := NewRedisBlacklist(client, "drei-test:"+time.Now().Format(time.RFC3339Nano)+":")
+
+	assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{
+		ChatID:      -1,
+		UserID:      1,
+		MsgID:       2,
+		Answer:      "画蛇添足",
+		ExpireAt:    time.Now().Add(time.Minute),
+		UserLink:    "UserLink",
+		MsgTemplate: "MsgTemplate %d",
+	}))
+
+	t.Run("读取记录", func(t *testing.T) {
+		item, err := bl.GetItem(ctx, -1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, model.Blacklist{
+			ChatID:      -1,
+			UserID:      1,
+			MsgID:       2,
+			Answer:      "画蛇添足",
+			ExpireAt:    item.ExpireAt,
+			UserLink:    "UserLink",
+			MsgTemplate: "MsgTemplate %d",
+		}, *item)
+
+		item, err = bl.GetItemByMsgID(ctx, -1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, item.UserID)
+
+		_, err = bl.GetItem(ctx, -1, 2)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, -1, 3)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("更新验证码", func(t *testing.T) {
+		refreshAt := time.Now()
+		assert.NoError(t, bl.UpdateAnswer(ctx, -1, 1, "一心一意", []string{"一心一意", "三心二意"}, 0, refreshAt))
+		item, err := bl.GetItem(ctx, -1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "一心一意", item.Answer)
+		assert.Equal(t, []string{"一心一意", "三心二意"}, item.Choices)
+		assert.Equal(t, 1, item.Refreshes)
+		assert.Equal(t, refreshAt.UnixNano(), item.RefreshAt.UnixNano())
+
+		assert.Equal(t, ErrNotFound, bl.UpdateAnswer(ctx, -1, 1, "三心二意", nil, 0, refreshAt), "刷新次数不匹配时不应更新")
+		assert.Equal(t, ErrNotFound, bl.UpdateAnswer(ctx, -1, 2, "一心一意", nil, 0, refreshAt))
+		_, err = bl.GetItem(ctx, -1, 2)
+		assert.Equal(t, ErrNotFound, err, "不存在的记录不应被创建")
+	})
+
+	t.Run("累加错误次数", func(t *testing.T) {
+		attempts, err := bl.IncrAttempts(ctx, -1, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+		_, err = bl.IncrAttempts(ctx, -1, 1, 3)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.IncrAttempts(ctx, -1, 2, 2)
+		assert.Equal(t, ErrNotFound, err)
+		item, err := bl.GetItem(ctx, -1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, item.Attempts)
+	})
+
+	t.Run("重新进群覆盖旧记录", func(t *testing.T) {
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: -1, UserID: 1, MsgID: 5, ExpireAt: time.Now().Add(time.Minute)}))
+		_, err := bl.GetItemByMsgID(ctx, -1, 2)
+		assert.Equal(t, ErrNotFound, err)
+		item, err := bl.GetItemByMsgID(ctx, -1, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, "", item.Answer)
+	})
+
+	t.Run("删除记录", func(t *testing.T) {
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, -1, 1, 2), "旧验证码不应删除新记录")
+		assert.NoError(t, bl.DeleteItem(ctx, -1, 1, 5))
+		assert.Equal(t, ErrNotFound, bl.DeleteItem(ctx, -1, 1, 5))
+		_, err := bl.GetItem(ctx, -1, 1)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, -1, 5)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("记录过期", func(t *testing.T) {
+		if fastForward == nil {
+			t.Skip("redis-server 不支持快进时间")
+		}
+		assert.NoError(t, bl.CreateItem(ctx, model.Blacklist{ChatID: -1, UserID: 1, MsgID: 6, ExpireAt: time.Now().Add(time.Minute)}))
+		fastForward(time.Minute)
+		_, err := bl.GetItem(ctx, -1, 1)
+		assert.NoError(t, err, "过期时刻仍可读取")
+
+		fastForward(expireGrace + time.Second)
+		_, err = bl.GetItem(ctx, -1, 1)
+		assert.Equal(t, ErrNotFound, err)
+		_, err = bl.GetItemByMsgID(ctx, -1, 6)
+		assert.Equal(t, ErrNotFound, err)
+	})
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+type Settings struct {
+	db        *dynamodb.DynamoDB
+	tableName *string
+}
+
+func NewSettings(p client.ConfigProvider, tableName string) ISettings {
+	return &Settings{
+		db:        dynamodb.New(p),
+		tableName: &tableName,
+	}
+}
+
+func (s Settings) GetSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: s.tableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"chatID": {N: Blacklist{}.i64ToStr(chatID)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 以默认设置为底，旧记录中缺失的新设置项将保持默认值
+	settings := model.DefaultChatSettings(chatID)
+	if len(result.Item) == 0 {
+		return &settings, nil
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &settings); err != nil {
+		return nil, xerrors.Errorf("unmarshal settings failed: %v: %w", err, ErrMalformedItem)
+	}
+	return &settings, nil
+}
+
+func (s Settings) PutSettings(ctx context.Context, settings model.ChatSettings) error {
+	item, err := dynamodbattribute.MarshalMap(settings)
+	if err != nil {
+		return xerrors.Errorf("marshal settings failed: %w", err)
+	}
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: s.tableName,
+	})
+	if err != nil {
+		return xerrors.Errorf("put settings failed: %w", err)
+	}
+	return nil
+}
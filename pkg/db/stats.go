@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jqs7/drei/pkg/model"
+	"golang.org/x/xerrors"
+)
+
+// Stats 以 chatID 为分区键、日期为排序键保存每日计数，计数通过 ADD 原子累加
+type Stats struct {
+	db        *dynamodb.DynamoDB
+	tableName *string
+}
+
+func NewStats(p client.ConfigProvider, tableName string) IStats {
+	return &Stats{
+		db:        dynamodb.New(p),
+		tableName: &tableName,
+	}
+}
+
+func (s Stats) IncrStat(ctx context.Context, chatID int64, day int, field string) error {
+	_, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: s.tableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"chatID": {N: aws.String(strconv.FormatInt(chatID, 10))},
+			"day":    {N: aws.String(strconv.Itoa(day))},
+		},
+		UpdateExpression: aws.String("ADD #field :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#field": aws.String(field),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("incr stat %s failed: %w", field, err)
+	}
+	return nil
+}
+
+func (s Stats) ListStats(ctx context.Context, chatID int64, sinceDay int) ([]model.DailyStats, error) {
+	var (
+		stats        []model.DailyStats
+		unmarshalErr error
+	)
+	input := &dynamodb.QueryInput{
+		TableName:              s.tableName,
+		KeyConditionExpression: aws.String("chatID = :chatID AND #day >= :day"),
+		ExpressionAttributeNames: map[string]*string{
+			"#day": aws.String("day"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":chatID": {N: aws.String(strconv.FormatInt(chatID, 10))},
+			":day":    {N: aws.String(strconv.Itoa(sinceDay))},
+		},
+	}
+	err := s.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, v := range page.Items {
+			item := model.DailyStats{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(v, &item); unmarshalErr != nil {
+				return false
+			}
+			stats = append(stats, item)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("list stats failed: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, xerrors.Errorf("unmarshal stats failed: %v: %w", unmarshalErr, ErrMalformedItem)
+	}
+	return stats, nil
+}
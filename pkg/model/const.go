@@ -8,19 +8,169 @@ const (
 	CallbackTypeRefresh     = "Refresh"
 	CallbackTypePassThrough = "PassThrough"
 	CallbackTypeKick        = "Kick"
+	// CallbackTypeChoice 为选项按钮的前缀，完整格式为 Choice:<选项序号>:<签名>
+	CallbackTypeChoice = "Choice:"
 
 	CallbackTypeDonateWX     = "DonateWX"
 	CallbackTypeDonateAlipay = "DonateAlipay"
+
+	// CallbackTypeSettings 为群组设置按钮的前缀，完整格式为 Settings:<设置项>
+	CallbackTypeSettings            = "Settings:"
+	CallbackTypeSettingsTimeout     = CallbackTypeSettings + "Timeout"
+	CallbackTypeSettingsBanDuration = CallbackTypeSettings + "BanDuration"
+	CallbackTypeSettingsCaptchaType = CallbackTypeSettings + "CaptchaType"
+	CallbackTypeSettingsDeleteJoin  = CallbackTypeSettings + "DeleteJoinMsg"
+	CallbackTypeSettingsMaxAttempts = CallbackTypeSettings + "MaxAttempts"
+	CallbackTypeSettingsChoiceMode  = CallbackTypeSettings + "ChoiceMode"
+	CallbackTypeSettingsFuzzyMatch  = CallbackTypeSettings + "FuzzyMatch"
+	CallbackTypeSettingsPinyin      = CallbackTypeSettings + "PinyinAnswer"
+	CallbackTypeSettingsDifficulty  = CallbackTypeSettings + "Difficulty"
+	CallbackTypeSettingsAnimated    = CallbackTypeSettings + "Animated"
+	CallbackTypeSettingsClose       = CallbackTypeSettings + "Close"
+)
+
+const (
+	CommandSettings = "settings"
+	CommandHistory  = "history"
+	CommandStats    = "stats"
+)
+
+const (
+	AuditEventJoin        = "join"
+	AuditEventRefresh     = "refresh"
+	AuditEventWrongAnswer = "wrongAnswer"
+	AuditEventPass        = "pass"
+	AuditEventAdminPass   = "adminPass"
+	AuditEventAdminKick   = "adminKick"
+	AuditEventTimeoutKick = "timeoutKick"
+	AuditEventLeave       = "leave"
+	AuditEventAttemptKick = "attemptKick"
 )
 
+var AuditEventNames = map[string]string{
+	AuditEventJoin:        "进群并收到验证码",
+	AuditEventRefresh:     "刷新验证码",
+	AuditEventWrongAnswer: "回答错误",
+	AuditEventPass:        "验证通过",
+	AuditEventAdminPass:   "管理员令其通过验证",
+	AuditEventAdminKick:   "管理员将其踢出",
+	AuditEventTimeoutKick: "验证超时被移出",
+	AuditEventLeave:       "验证期间退群",
+	AuditEventAttemptKick: "错误次数过多被移出",
+}
+
+// 统计项，与 DailyStats 的字段一一对应
+const (
+	StatChallenged = "challenged"
+	StatPassed     = "passed"
+	StatTimedOut   = "timedOut"
+	StatKicked     = "kicked"
+	StatLockedOut  = "lockedOut"
+)
+
+// AuditEventStats 为计入统计的审计事件及其对应的统计项
+var AuditEventStats = map[string]string{
+	AuditEventJoin:        StatChallenged,
+	AuditEventPass:        StatPassed,
+	AuditEventAdminPass:   StatPassed,
+	AuditEventTimeoutKick: StatTimedOut,
+	AuditEventAdminKick:   StatKicked,
+	AuditEventAttemptKick: StatLockedOut,
+}
+
+// StatsPeriods 为 /stats 展示的统计天数
+var StatsPeriods = []int{1, 7, 30}
+
+const (
+	DefaultHistoryLimit = 10
+	MaxHistoryLimit     = 50
+)
+
+const (
+	CaptchaTypeIdiom = "idiom"
+	CaptchaTypeMath  = "math"
+	CaptchaTypeText  = "text"
+)
+
+var CaptchaTypeNames = map[string]string{
+	CaptchaTypeIdiom: "成语",
+	CaptchaTypeMath:  "算术",
+	CaptchaTypeText:  "字母数字",
+}
+
+// CaptchaHints 为各类验证码在验证消息中的答题提示
+var CaptchaHints = map[string]string{
+	CaptchaTypeIdiom: "请发送以上 <b>【四字】</b> 验证码内容",
+	CaptchaTypeMath:  "请发送以上算式的计算结果（Please reply with the result of the expression above）",
+	CaptchaTypeText:  "请发送以上图片中的字母及数字，不区分大小写（Please reply with the letters and digits above, case-insensitive）",
+}
+
+// DefaultCaptchaHint 为未注册的验证码类型在验证消息中的答题提示
+const DefaultCaptchaHint = "请发送以上验证码内容（Please reply with the captcha above）"
+
+// 成语难度，未标注难度的成语视为困难
+const (
+	IdiomDifficultyEasy   = 1
+	IdiomDifficultyMedium = 2
+	IdiomDifficultyHard   = 3
+)
+
+// DifficultyOptions 为群组成语难度的可选值，0 表示不限
+var DifficultyOptions = []int{0, IdiomDifficultyEasy, IdiomDifficultyMedium}
+
+var DifficultyNames = map[int]string{
+	0:                     "不限",
+	IdiomDifficultyEasy:   "简单",
+	IdiomDifficultyMedium: "普通",
+	IdiomDifficultyHard:   "困难",
+}
+
+// PinyinHint 为成语验证码开启拼音答题后追加的答题提示
+const PinyinHint = "，也可发送不带声调的拼音（or reply with its toneless pinyin, e.g. hua she tian zu）"
+
+// ChoiceHint 为按钮答题模式下的答题提示
+const ChoiceHint = "请点击下方与以上图片相符的选项，选错将被移出群组（Please tap the option matching the image above, a wrong choice gets you removed）"
+
 const (
 	UserLinkTemplate = `<a href="tg://user?id=%d">%s</a>`
-	EnterRoomMsg     = ` 你好，欢迎加入 %s，本群已启用新成员验证模式，请发送以上 <b>【四字】</b> 验证码内容。
-在验证通过之前，你所发送的所有消息都将会被删除。
-本消息将在 %%d 秒后失效，届时若未通过验证，你将被移出群组，且一分钟之内无法再加入本群。`
-	HelpMsg = `欢迎使用进群验证码机器人
+	EnterRoomMsg     = ` 你好，欢迎加入 %s，本群已启用新成员验证模式，%s。
+在验证通过之前，你所发送的所有消息都将会被删除。`
+	ExpireNoticeMsg = `本消息将在 %%d 秒后失效，届时若未通过验证，你将被移出群组，且%s无法再加入本群。`
+	AttemptsLeftMsg = `
+剩余尝试次数：%d，用尽后将被移出群组。`
+	SettingsMsg = `<b>本群验证设置</b>
+验证时限：%d 秒
+超时封禁：%s
+验证码类型：%s
+删除进群消息：%s
+错误次数上限：%s
+答题方式：%s
+容错匹配：%s
+拼音答题：%s
+成语难度：%s
+自定义词库：%s
+动图验证码：%s
+欢迎语：%s
+
+点击下方按钮切换设置，发送 <code>/settings welcome 欢迎语</code> 可自定义欢迎语，发送 <code>/settings welcome</code> 恢复默认欢迎语。
+发送 <code>/settings words 成语1 成语2 ...</code> 可设置自定义词库，发送 <code>/settings words</code> 清空词库。
+词语较多时可发送每行一个词语的 .txt 文件，并以 <code>/settings words</code> 作为文件说明。`
+	WelcomeTooLongMsg = `欢迎语设置失败：共 %d 个字符，最多 %d 个字符。`
+	WordsInvalidMsg   = `自定义词库设置失败：%s
+词库须包含 %d 至 %d 个互不相同的四字中文词语，以空格或逗号分隔。`
+	WordsPinyinConflictMsg = `自定义词库设置失败：自定义词库的词语没有拼音，请先关闭拼音答题。`
+	WordsFileInvalidMsg    = `自定义词库设置失败：请发送不超过 %d KB、每行一个词语的 .txt 文件。`
+	HistoryMsg             = `<b>用户 %d 最近 %d 条验证记录</b>
+%s`
+	HistoryUsageMsg = `用法：<code>/history 用户ID [条数]</code>`
+	StatsMsg        = `<b>本群验证统计</b>
+%s`
+	StatsLineMsg = `近 %d 天：验证 %d 人，通过 %d 人，超时 %d 人，管理员踢出 %d 人，错误过多移出 %d 人`
+	HelpMsg      = `欢迎使用进群验证码机器人
 本机器人使用姿势：
 将本机器人加入需要启用验证的群组，设置为管理员，并授予 Delete messages，Ban users 权限即可
+群组管理员可在群组中发送 /settings 调整验证时限、超时封禁时长等设置
+发送 /history 用户ID 可查看该用户最近的验证记录，发送 /stats 可查看本群验证统计
 本项目开源于：https://github.com/jqs7/drei
 若本项目对你有所帮助，可点击 /donate 为本项目捐款`
 	DonateMsg = `所捐款项将用于：
@@ -30,6 +180,31 @@ const (
 
 const (
 	CaptchaRefreshSecond = 15
+	DefaultTimeout       = 300
+	DefaultBanDuration   = 60
+	DefaultMaxAttempts   = 5
+	// RefreshCooldownSecond 为两次刷新验证码的最短间隔，MaxRefreshes 为每个验证码的刷新次数上限
+	RefreshCooldownSecond = 10
+	MaxRefreshes          = 5
+	// ChoiceCount 为按钮答题模式下的选项个数
+	ChoiceCount = 4
+	// ChoiceMaxAttempts 为点击选项的错误次数上限，逐个点击即可试出答案，因此选错一次即移出
+	ChoiceMaxAttempts = 1
+	// MaxCustomWords 为自定义词库的词数上限，词数下限为 ChoiceCount
+	MaxCustomWords = 200
+	// MaxWordsFileSize 为自定义词库文件的大小上限，单位为字节
+	MaxWordsFileSize = 32 * 1024
+	// MaxWelcomeMsgLen 为自定义欢迎语的长度上限，按 UTF-16 编码单元计，
+	// 为用户名、答题提示等预留空间，使验证消息不超过 Telegram 图片说明 1024 字符的上限
+	MaxWelcomeMsgLen = 500
+)
+
+// TimeoutOptions 与 BanDurationOptions 为设置按钮依次切换的可选值，单位为秒
+var (
+	TimeoutOptions     = []int{60, 120, 180, DefaultTimeout, 600}
+	BanDurationOptions = []int{DefaultBanDuration, 600, 3600, 86400, 0}
+	// MaxAttemptsOptions 为错误次数上限的可选值，0 表示不限
+	MaxAttemptsOptions = []int{3, DefaultMaxAttempts, 10, 0}
 )
 
 type DonateKV struct {
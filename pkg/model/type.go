@@ -26,19 +26,112 @@ type MsgToRepeat struct {
 type Idiom struct {
 	ID   int64
 	Word string
+	// Pinyin 为带声调的拼音，如 "huà shé tiān zú"，缺失时该成语不接受拼音答案
+	Pinyin string
+	// Difficulty 为成语难度，取值为 IdiomDifficulty*，未标注时视为困难
+	Difficulty int
 }
 
 type Blacklist struct {
-	ChatID      int64
-	UserID      int
-	MsgID       int
-	Index       int
+	ChatID int64
+	UserID int
+	MsgID  int
+	// Answer 为生成验证码时得到的答案令牌，只由对应类型的验证码解读
+	Answer      string
 	ExpireAt    time.Time
 	UserLink    string
 	MsgTemplate string
+	// Attempts 为已回答错误的次数，MaxAttempts 为进群时群组设置的错误次数上限，0 表示不限
+	Attempts    int
+	MaxAttempts int
+	// Refreshes 为已刷新验证码的次数，RefreshAt 为最近一次刷新的时间，未刷新时为零值
+	Refreshes int
+	RefreshAt time.Time
+	// CaptchaType 为发出验证码时群组设置的验证码类型，旧记录为空时视为成语验证码
+	CaptchaType string
+	// Choices 为按钮答题模式下的选项，输入答题模式下为空
+	Choices []string
 }
 
+// Answer 为验证码答案。生成验证码时答案记录于 Token，校验用户回答时，用户的输入记录于 String；
+// 旧版本以词典下标记录答案，这类记录的 Token 为空
 type Answer struct {
-	Number int
 	String string
+	Token  string
+}
+
+// ChatSettings 为群组级别的验证设置
+type ChatSettings struct {
+	ChatID int64 `json:"chatID"`
+	// Timeout 为验证时限，单位为秒
+	Timeout int `json:"timeout"`
+	// BanDuration 为验证超时被移出后禁止再次加入的时长，单位为秒，0 表示永久
+	BanDuration   int    `json:"banDuration"`
+	CaptchaType   string `json:"captchaType"`
+	WelcomeMsg    string `json:"welcomeMsg"`
+	DeleteJoinMsg bool   `json:"deleteJoinMsg"`
+	// MaxAttempts 为回答错误的次数上限，达到上限立即移出群组，0 表示不限
+	MaxAttempts int `json:"maxAttempts"`
+	// ChoiceMode 开启时通过点击选项按钮答题，无需输入
+	ChoiceMode bool `json:"choiceMode"`
+	// FuzzyMatch 开启时成语验证码的答案允许错一个字
+	FuzzyMatch bool `json:"fuzzyMatch"`
+	// PinyinAnswer 开启时成语验证码接受不带声调的拼音答案
+	PinyinAnswer bool `json:"pinyinAnswer"`
+	// Difficulty 为成语验证码出题的最高难度，0 表示不限
+	Difficulty int `json:"difficulty"`
+	// Words 为自定义词库，非空时成语验证码只从中出题
+	Words []string `json:"words"`
+	// Animated 开启时以逐帧显示的 GIF 动图发送验证码，防止单帧识别
+	Animated bool `json:"animated"`
+}
+
+func DefaultChatSettings(chatID int64) ChatSettings {
+	return ChatSettings{
+		ChatID:        chatID,
+		Timeout:       DefaultTimeout,
+		BanDuration:   DefaultBanDuration,
+		CaptchaType:   CaptchaTypeIdiom,
+		DeleteJoinMsg: true,
+		MaxAttempts:   DefaultMaxAttempts,
+	}
+}
+
+// AuditEvent 为验证过程中的一条审计记录
+type AuditEvent struct {
+	ChatID int64 `json:"chatID"`
+	UserID int   `json:"userID"`
+	// ActorID 为触发事件的用户，管理员操作时为管理员，超时等系统操作时为 0
+	ActorID int       `json:"actorID"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+}
+
+// LocalZone 为展示时间及按天统计时使用的时区
+var LocalZone = time.FixedZone("CST", 8*60*60)
+
+// StatsDay 返回 t 在 LocalZone 下的日期，形如 20200101
+func StatsDay(t time.Time) int {
+	y, m, d := t.In(LocalZone).Date()
+	return y*10000 + int(m)*100 + d
+}
+
+// DailyStats 为群组单日的验证统计
+type DailyStats struct {
+	ChatID     int64 `json:"chatID"`
+	Day        int   `json:"day"`
+	Challenged int   `json:"challenged"`
+	Passed     int   `json:"passed"`
+	TimedOut   int   `json:"timedOut"`
+	Kicked     int   `json:"kicked"`
+	LockedOut  int   `json:"lockedOut"`
+}
+
+// Add 将 other 的计数累加至 s
+func (s *DailyStats) Add(other DailyStats) {
+	s.Challenged += other.Challenged
+	s.Passed += other.Passed
+	s.TimedOut += other.TimedOut
+	s.Kicked += other.Kicked
+	s.LockedOut += other.LockedOut
 }
@@ -3,9 +3,13 @@ package utils
 import (
 	"encoding/json"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"log"
 	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 func EncodeToString(i interface{}) string {
@@ -24,12 +28,13 @@ func GetFullName(firstName, lastName string) string {
 	return fullName
 }
 
-func UpdateMsgPhoto(
+// UpdateMsgMedia 将消息的媒体替换为 mediaType 类型（photo、animation 等）的文件，file 为 file_id 时不重新上传
+func UpdateMsgMedia(
 	bot *tgbotapi.BotAPI, chatID int64, messageID int,
-	caption, parseMode string,
+	mediaType, caption, parseMode string,
 	markup tgbotapi.InlineKeyboardMarkup, file interface{},
 ) (*tgbotapi.Message, error) {
-	media := "attach://photo"
+	media := "attach://" + mediaType
 	fileID, withFileID := file.(string)
 	if withFileID {
 		media = fileID
@@ -40,7 +45,7 @@ func UpdateMsgPhoto(
 		Caption   string `json:"caption"`
 		ParseMode string `json:"parse_mode"`
 	}{
-		Type:      "photo",
+		Type:      mediaType,
 		Media:     media,
 		Caption:   caption,
 		ParseMode: parseMode,
@@ -72,10 +77,39 @@ func UpdateMsgPhoto(
 		message := &tgbotapi.Message{}
 		return message, json.Unmarshal(resp.Result, message)
 	}
-	resp, err := bot.UploadFile("editMessageMedia", reqParam, "photo", file)
+	resp, err := bot.UploadFile("editMessageMedia", reqParam, mediaType, file)
 	if err != nil {
 		return nil, err
 	}
 	message := &tgbotapi.Message{}
 	return message, json.Unmarshal(resp.Result, message)
 }
+
+// FormatDuration 将秒数格式化为便于阅读的时长，0 表示永久
+func FormatDuration(sec int) string {
+	switch {
+	case sec <= 0:
+		return "永久"
+	case sec%86400 == 0:
+		return strconv.Itoa(sec/86400) + " 天"
+	case sec%3600 == 0:
+		return strconv.Itoa(sec/3600) + " 小时"
+	case sec%60 == 0:
+		return strconv.Itoa(sec/60) + " 分钟"
+	default:
+		return strconv.Itoa(sec) + " 秒"
+	}
+}
+
+// CloseOnSignal 在收到 SIGTERM 或 SIGINT 时调用 close 释放连接或文件后退出进程
+func CloseOnSignal(close func() error) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sig
+		if err := close(); err != nil {
+			log.Println("close failed: ", err)
+		}
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,94 @@
+package verifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jqs7/drei/pkg/db"
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// CallbackSecret 返回选项按钮签名所用的密钥，未设置 CALLBACK_SECRET 时使用 BOT_TOKEN
+func CallbackSecret() []byte {
+	if secret := os.Getenv("CALLBACK_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("BOT_TOKEN"))
+}
+
+// choiceSig 对选项序号签名，签名与用户及刷新次数绑定，
+// 伪造的按钮数据及刷新前的旧按钮均无法通过校验
+func choiceSig(secret []byte, blacklist model.Blacklist, idx int) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%d:%d:%d:%d", blacklist.ChatID, blacklist.UserID, blacklist.Refreshes, idx)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+func choiceData(secret []byte, blacklist model.Blacklist, idx int) string {
+	return model.CallbackTypeChoice + strconv.Itoa(idx) + ":" + choiceSig(secret, blacklist, idx)
+}
+
+// parseChoice 校验按钮数据的签名并返回选项序号
+func parseChoice(secret []byte, blacklist model.Blacklist, data string) (int, bool) {
+	fields := strings.SplitN(strings.TrimPrefix(data, model.CallbackTypeChoice), ":", 2)
+	if len(fields) != 2 {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(fields[0])
+	if err != nil || idx < 0 || idx >= len(blacklist.Choices) {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(fields[1]), []byte(choiceSig(secret, blacklist, idx))) {
+		return 0, false
+	}
+	return idx, true
+}
+
+// Keyboard 返回验证码消息的按钮，按钮答题模式下在 InlineKeyboard 之前附加每行两个的选项按钮
+func Keyboard(blacklist model.Blacklist, secret []byte) [][]model.KV {
+	if len(blacklist.Choices) == 0 {
+		return InlineKeyboard
+	}
+	var keyboard [][]model.KV
+	for i, v := range blacklist.Choices {
+		if i%2 == 0 {
+			keyboard = append(keyboard, []model.KV{})
+		}
+		row := &keyboard[len(keyboard)-1]
+		*row = append(*row, model.KV{K: v, V: choiceData(secret, blacklist, i)})
+	}
+	return append(keyboard, InlineKeyboard...)
+}
+
+func (ic IdiomVerifier) onChoice(ctx context.Context, chatID int64, msgID, fromUser int, callbackID, data string) {
+	blacklist, err := ic.blacklist.GetItem(ctx, chatID, fromUser)
+	if err != nil || blacklist.MsgID != msgID {
+		if err == nil || err == db.ErrNotFound {
+			ic.bot.AnswerCallback(callbackID, "无权限")
+		}
+		return
+	}
+	idx, ok := parseChoice(ic.secret, *blacklist, data)
+	if !ok {
+		ic.bot.AnswerCallback(callbackID, "选项已失效")
+		return
+	}
+	_, verifier := ic.captchaOf(blacklist.CaptchaType)
+	if verifier.VerifyAnswer(answerOf(*blacklist), model.Answer{String: blacklist.Choices[idx]}, choiceOptions(GetSettings(ctx, ic.settings, chatID))) {
+		if err := ic.verifyOK(ctx, *blacklist, model.AuditEventPass, fromUser); err != nil {
+			ic.bot.AnswerCallback(callbackID, "验证失败")
+			return
+		}
+		ic.bot.AnswerCallback(callbackID, "验证通过")
+		return
+	}
+	blacklist.MaxAttempts = model.ChoiceMaxAttempts
+	ic.onWrongAnswer(ctx, *blacklist)
+	ic.bot.AnswerCallback(callbackID, "回答错误")
+}
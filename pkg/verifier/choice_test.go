@@ -0,0 +1,151 @@
+package verifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jqs7/drei/pkg/bot"
+	"github.com/jqs7/drei/pkg/captcha"
+	"github.com/jqs7/drei/pkg/db"
+	"github.com/jqs7/drei/pkg/model"
+	"github.com/jqs7/drei/pkg/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChoiceCaptcha(t *testing.T) {
+	ctx := context.Background()
+	choices := []string{"一心一意", "三心二意", "五湖四海", "七上八下"}
+
+	userEnterGroup := func(t *testing.T, ctrl *gomock.Controller) (*bot.MockInterface, *captcha.MockInterface, db.IBlacklist, Interface, [][]model.KV) {
+		settings := db.NewMemorySettings()
+		chatSettings := model.DefaultChatSettings(1)
+		chatSettings.ChoiceMode = true
+		assert.NoError(t, settings.PutSettings(ctx, chatSettings))
+
+		var keyboard [][]model.KV
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ int64, _ []byte, caption string, kb [][]model.KV) (int, error) {
+				assert.Contains(t, caption, model.ChoiceHint)
+				keyboard = kb
+				return 2, nil
+			}).Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+		imgVerifier := captcha.NewMockInterface(ctrl)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Return(model.Answer{Token: "三心二意"}, nil).Times(1)
+		imgVerifier.EXPECT().GenChoices(model.Answer{Token: "三心二意"}, model.ChoiceCount, captcha.Options{}).Return(choices).Times(1)
+		imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "三心二意"}, gomock.Any(), captcha.Options{}).
+			DoAndReturn(func(_, request model.Answer, _ captcha.Options) bool {
+				return request.String == "三心二意"
+			}).AnyTimes()
+
+		blacklist := db.NewMemoryBlacklist()
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, blacklist, settings, newTestRecorder(), idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+		assert.Len(t, keyboard, 2+len(InlineKeyboard))
+		return mockBot, imgVerifier, blacklist, verifier, keyboard
+	}
+
+	choiceData := func(keyboard [][]model.KV, choice string) string {
+		for _, row := range keyboard {
+			for _, v := range row {
+				if v.K == choice {
+					return v.V
+				}
+			}
+		}
+		return ""
+	}
+
+	t.Run("点击正确选项", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, blacklist, verifier, keyboard := userEnterGroup(t, ctrl)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsg(int64(1), gomock.Any()).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "验证通过").Times(1)
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", choiceData(keyboard, "三心二意"))
+		_, err := blacklist.GetItem(ctx, 1, 1)
+		assert.Equal(t, db.ErrNotFound, err)
+	})
+
+	t.Run("点击错误选项", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, blacklist, verifier, keyboard := userEnterGroup(t, ctrl)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().Kick(int64(1), 1, gomock.Any()).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "回答错误").Times(1)
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", choiceData(keyboard, "一心一意"))
+		_, err := blacklist.GetItem(ctx, 1, 1)
+		assert.Equal(t, db.ErrNotFound, err)
+	})
+
+	t.Run("逐个点击所有选项无法通过", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, blacklist, verifier, keyboard := userEnterGroup(t, ctrl)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().Kick(int64(1), 1, gomock.Any()).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "回答错误").Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "无权限").Times(len(choices) - 1)
+		for _, v := range choices {
+			verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", choiceData(keyboard, v))
+		}
+		_, err := blacklist.GetItem(ctx, 1, 1)
+		assert.Equal(t, db.ErrNotFound, err)
+	})
+
+	t.Run("伪造选项数据", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, _, verifier, keyboard := userEnterGroup(t, ctrl)
+		mockBot.EXPECT().AnswerCallback("callbackID", "选项已失效").Times(2)
+		data := choiceData(keyboard, "一心一意")
+		idx := strings.Index(data[len(model.CallbackTypeChoice):], ":") + len(model.CallbackTypeChoice)
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", model.CallbackTypeChoice+"1"+data[idx:])
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", model.CallbackTypeChoice+"1:forged")
+	})
+
+	t.Run("其他用户点击选项", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, _, _, verifier, keyboard := userEnterGroup(t, ctrl)
+		mockBot.EXPECT().AnswerCallback("callbackID", "无权限").Times(1)
+		verifier.OnCallbackQuery(ctx, 1, 2, 3, "callbackID", choiceData(keyboard, "三心二意"))
+	})
+
+	t.Run("刷新时选项不足则保留原题目", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot, imgVerifier, blacklist, verifier, keyboard := userEnterGroup(t, ctrl)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Return(model.Answer{Token: "五湖四海"}, nil).Times(1)
+		imgVerifier.EXPECT().GenChoices(model.Answer{Token: "五湖四海"}, model.ChoiceCount, captcha.Options{}).
+			Return([]string{"五湖四海"}).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "刷新失败").Times(1)
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", model.CallbackTypeRefresh)
+		item, err := blacklist.GetItem(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "三心二意", item.Answer)
+		assert.Equal(t, choices, item.Choices)
+		assert.Equal(t, 0, item.Refreshes)
+
+		// 原选项仍可作答
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mockBot.EXPECT().SendMsg(int64(1), gomock.Any()).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "验证通过").Times(1)
+		verifier.OnCallbackQuery(ctx, 1, 2, 1, "callbackID", choiceData(keyboard, "三心二意"))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"html"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/jqs7/drei/pkg/bot"
@@ -14,6 +15,7 @@ import (
 	"github.com/jqs7/drei/pkg/model"
 	"github.com/jqs7/drei/pkg/queue"
 	"github.com/jqs7/drei/pkg/utils"
+	"golang.org/x/xerrors"
 )
 
 type IdiomVerifier struct {
@@ -22,7 +24,11 @@ type IdiomVerifier struct {
 	delMsgQueue    string
 	countDownQueue string
 	blacklist      db.IBlacklist
-	captcha        captcha.Interface
+	settings       db.ISettings
+	recorder       Recorder
+	captchas       map[string]captcha.Interface
+	defaultCaptcha string
+	secret         []byte
 }
 
 func (ic IdiomVerifier) OnLeftMember(ctx context.Context, chatID int64, leftMemberID int) {
@@ -30,8 +36,14 @@ func (ic IdiomVerifier) OnLeftMember(ctx context.Context, chatID int64, leftMemb
 	if err != nil {
 		return
 	}
+	if err := ic.blacklist.DeleteItem(ctx, chatID, leftMemberID, blacklist.MsgID); err != nil {
+		if err != db.ErrNotFound {
+			log.Println("delete item failed: ", err)
+		}
+		return
+	}
 	ic.bot.DeleteMsg(chatID, blacklist.MsgID)
-	ic.blacklist.DeleteItem(ctx, chatID, leftMemberID)
+	ic.recorder.Record(ctx, chatID, leftMemberID, leftMemberID, model.AuditEventLeave)
 }
 
 func (ic IdiomVerifier) Verify(ctx context.Context, chatID int64, userID, msgID int, msg string) {
@@ -40,19 +52,78 @@ func (ic IdiomVerifier) Verify(ctx context.Context, chatID int64, userID, msgID
 		return
 	}
 	ic.bot.DeleteMsg(chatID, msgID)
-	if ic.captcha.VerifyAnswer(model.Answer{Number: blacklist.Index}, model.Answer{String: msg}) {
-		ic.bot.DeleteMsg(blacklist.ChatID, blacklist.MsgID)
-		ic.verifyOK(ctx, *blacklist)
+	// 贴纸、图片等非文本消息不视为作答，不计入错误次数
+	if msg == "" {
 		return
 	}
+	// 升级前发出的验证码以词典下标记录答案，词典更换后下标可能指向其他成语，
+	// 无法确定正确答案，因此重新出题，且不计入错误次数
+	if blacklist.Answer == "" {
+		if err := ic.reissue(ctx, *blacklist, time.Now()); err != nil && err != db.ErrNotFound {
+			log.Println("reissue captcha failed: ", err)
+		}
+		return
+	}
+	_, verifier := ic.captchaOf(blacklist.CaptchaType)
+	opts := captchaOptions(GetSettings(ctx, ic.settings, chatID))
+	if verifier.VerifyAnswer(answerOf(*blacklist), model.Answer{String: msg}, opts) {
+		_ = ic.verifyOK(ctx, *blacklist, model.AuditEventPass, userID)
+		return
+	}
+	ic.onWrongAnswer(ctx, *blacklist)
 }
 
-func (ic IdiomVerifier) verifyOK(ctx context.Context, blacklist model.Blacklist) {
-	ic.blacklist.DeleteItem(ctx, blacklist.ChatID, blacklist.UserID)
-	msgID, err := ic.bot.SendMsg(blacklist.ChatID, blacklist.UserLink+" 恭喜，你已验证通过")
+// onWrongAnswer 累加错误次数，达到上限时移出用户，否则更新剩余次数
+func (ic IdiomVerifier) onWrongAnswer(ctx context.Context, blacklist model.Blacklist) {
+	attempts, err := ic.blacklist.IncrAttempts(ctx, blacklist.ChatID, blacklist.UserID, blacklist.MsgID)
 	if err != nil {
+		if err != db.ErrNotFound {
+			log.Println("incr attempts failed: ", err)
+		}
 		return
 	}
+	ic.recorder.Record(ctx, blacklist.ChatID, blacklist.UserID, blacklist.UserID, model.AuditEventWrongAnswer)
+	if blacklist.MaxAttempts <= 0 {
+		return
+	}
+	blacklist.Attempts = attempts
+	if attempts >= blacklist.MaxAttempts {
+		ic.kickOnAttempts(ctx, blacklist)
+		return
+	}
+	ic.bot.UpdateCaption(blacklist.ChatID, blacklist.MsgID,
+		Caption(blacklist, time.Until(blacklist.ExpireAt)), Keyboard(blacklist, ic.secret),
+	)
+}
+
+// kickOnAttempts 在错误次数达到上限时移出用户，封禁时长与验证超时相同
+func (ic IdiomVerifier) kickOnAttempts(ctx context.Context, blacklist model.Blacklist) {
+	if err := ic.blacklist.DeleteItem(ctx, blacklist.ChatID, blacklist.UserID, blacklist.MsgID); err != nil {
+		if err != db.ErrNotFound {
+			log.Println("delete item failed: ", err)
+		}
+		return
+	}
+	ic.bot.DeleteMsg(blacklist.ChatID, blacklist.MsgID)
+	ic.bot.Kick(blacklist.ChatID, blacklist.UserID, BanUntil(GetSettings(ctx, ic.settings, blacklist.ChatID)))
+	ic.recorder.Record(ctx, blacklist.ChatID, blacklist.UserID, 0, model.AuditEventAttemptKick)
+}
+
+// verifyOK 删除验证记录后才移除验证码消息，若删除失败则保留验证码，以便用户重试；
+// 返回 db.ErrNotFound 表示该记录已被其他操作（超时、管理员踢出等）处理
+func (ic IdiomVerifier) verifyOK(ctx context.Context, blacklist model.Blacklist, eventType string, actorID int) error {
+	if err := ic.blacklist.DeleteItem(ctx, blacklist.ChatID, blacklist.UserID, blacklist.MsgID); err != nil {
+		if err != db.ErrNotFound {
+			log.Println("delete item failed: ", err)
+		}
+		return err
+	}
+	ic.bot.DeleteMsg(blacklist.ChatID, blacklist.MsgID)
+	ic.recorder.Record(ctx, blacklist.ChatID, blacklist.UserID, actorID, eventType)
+	msgID, err := ic.bot.SendMsg(blacklist.ChatID, blacklist.UserLink+" 恭喜，你已验证通过")
+	if err != nil {
+		return nil
+	}
 	err = ic.queue.SendMsg(ctx, ic.delMsgQueue, model.MsgToDelete{
 		ChatID: blacklist.ChatID,
 		MsgID:  msgID,
@@ -60,19 +131,60 @@ func (ic IdiomVerifier) verifyOK(ctx context.Context, blacklist model.Blacklist)
 	if err != nil {
 		log.Println("send delete msg: ", err)
 	}
+	return nil
 }
 
-func NewIdiomVerifier(bot bot.Interface, queue queue.Interface, blacklist db.IBlacklist, verifier captcha.Interface) (Interface, error) {
+// NewIdiomVerifier 的 captchas 为各验证码类型对应的实现，其中必须包含 defaultCaptcha，
+// 群组选择的验证码类型未启用时退回 defaultCaptcha
+func NewIdiomVerifier(bot bot.Interface, queue queue.Interface, blacklist db.IBlacklist, settings db.ISettings, recorder Recorder, captchas map[string]captcha.Interface, defaultCaptcha string) (Interface, error) {
+	if captchas[defaultCaptcha] == nil {
+		return nil, xerrors.Errorf("未配置默认验证码 %s", defaultCaptcha)
+	}
 	return &IdiomVerifier{
 		bot:            bot,
 		blacklist:      blacklist,
-		captcha:        verifier,
+		settings:       settings,
+		recorder:       recorder,
+		captchas:       captchas,
+		defaultCaptcha: defaultCaptcha,
+		secret:         CallbackSecret(),
 		queue:          queue,
 		delMsgQueue:    os.Getenv("DELETE_MSG_QUEUE"),
 		countDownQueue: os.Getenv("CAPTCHA_COUNTDOWN_QUEUE"),
 	}, nil
 }
 
+// captchaOf 返回验证码类型对应的实现及实际使用的类型
+func (ic IdiomVerifier) captchaOf(captchaType string) (string, captcha.Interface) {
+	if c, ok := ic.captchas[captchaType]; ok {
+		return captchaType, c
+	}
+	return ic.defaultCaptcha, ic.captchas[ic.defaultCaptcha]
+}
+
+// answerOf 返回验证记录中的答案
+func answerOf(blacklist model.Blacklist) model.Answer {
+	return model.Answer{Token: blacklist.Answer}
+}
+
+// captchaOptions 返回群组设置对应的出题及答案校验选项
+func captchaOptions(settings model.ChatSettings) captcha.Options {
+	return captcha.Options{
+		Fuzzy:      settings.FuzzyMatch,
+		Pinyin:     settings.PinyinAnswer,
+		Difficulty: settings.Difficulty,
+		Words:      settings.Words,
+		Animated:   settings.Animated,
+	}
+}
+
+// choiceOptions 返回按钮答题时的校验选项，选项须与答案完全一致
+func choiceOptions(settings model.ChatSettings) captcha.Options {
+	opts := captchaOptions(settings)
+	opts.Fuzzy, opts.Pinyin = false, false
+	return opts
+}
+
 var InlineKeyboard = [][]model.KV{
 	{
 		{K: "刷新验证码", V: model.CallbackTypeRefresh},
@@ -83,23 +195,94 @@ var InlineKeyboard = [][]model.KV{
 	},
 }
 
-func (ic IdiomVerifier) OnNewMember(ctx context.Context, chatID int64, chatName string, newMemberID int, firstName, lastName string) {
-	answer, img := ic.captcha.GenRandImg()
-	userLink := fmt.Sprintf(model.UserLinkTemplate, newMemberID, html.EscapeString(utils.GetFullName(firstName, lastName)))
-	msgTemplate := fmt.Sprintf(model.EnterRoomMsg, chatName)
-	msgID, err := ic.bot.SendImg(chatID, img, fmt.Sprintf(userLink+" "+msgTemplate, 300), InlineKeyboard)
+// GetSettings 读取群组设置，读取失败时退回默认设置，以免影响新成员验证
+func GetSettings(ctx context.Context, settings db.ISettings, chatID int64) model.ChatSettings {
+	s, err := settings.GetSettings(ctx, chatID)
 	if err != nil {
-		return
+		log.Printf("get settings of %d failed: %+v", chatID, err)
+		return model.DefaultChatSettings(chatID)
+	}
+	return *s
+}
+
+// MsgTemplate 生成验证码消息模板，模板中保留一个 %d 用于填充剩余秒数
+func MsgTemplate(chatName string, settings model.ChatSettings) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(html.EscapeString(s), "%", "%%")
+	}
+	hint := model.DefaultCaptchaHint
+	if p, ok := captcha.Lookup(settings.CaptchaType); ok {
+		hint = p.Hint
+	}
+	if settings.ChoiceMode {
+		hint = model.ChoiceHint
+	} else if settings.PinyinAnswer && settings.CaptchaType == model.CaptchaTypeIdiom && len(settings.Words) == 0 {
+		// 自定义词库的词语没有拼音，不提示拼音答题
+		hint += model.PinyinHint
+	}
+	welcome := fmt.Sprintf(model.EnterRoomMsg, escape(chatName), hint)
+	if settings.WelcomeMsg != "" {
+		// 自定义欢迎语只替换问候语，答题提示仍须保留
+		welcome = " " + escape(settings.WelcomeMsg) + "\n" + hint + "。"
+	}
+	banPeriod := "永久"
+	if settings.BanDuration > 0 {
+		banPeriod = utils.FormatDuration(settings.BanDuration) + "之内"
+	}
+	return welcome + "\n" + fmt.Sprintf(model.ExpireNoticeMsg, banPeriod)
+}
+
+// Caption 生成验证码消息的说明文字，left 为验证剩余时长
+func Caption(blacklist model.Blacklist, left time.Duration) string {
+	caption := fmt.Sprintf(blacklist.UserLink+" "+blacklist.MsgTemplate, left/time.Second)
+	if blacklist.MaxAttempts > 0 {
+		caption += fmt.Sprintf(model.AttemptsLeftMsg, blacklist.MaxAttempts-blacklist.Attempts)
 	}
-	ic.blacklist.CreateItem(ctx, model.Blacklist{
+	return caption
+}
+
+// BanUntil 返回验证超时后禁止用户再次加入的截止时间，零值时间表示永久
+func BanUntil(settings model.ChatSettings) time.Time {
+	if settings.BanDuration <= 0 {
+		return time.Unix(0, 0)
+	}
+	return time.Now().Add(time.Second * time.Duration(settings.BanDuration))
+}
+
+func (ic IdiomVerifier) OnNewMember(ctx context.Context, chatID int64, chatName string, newMemberID int, firstName, lastName string) {
+	settings := GetSettings(ctx, ic.settings, chatID)
+	captchaType, verifier := ic.captchaOf(settings.CaptchaType)
+	settings.CaptchaType = captchaType
+	opts := captchaOptions(settings)
+	answer, img := verifier.GenRandImg(opts)
+	userLink := fmt.Sprintf(model.UserLinkTemplate, newMemberID, html.EscapeString(utils.GetFullName(firstName, lastName)))
+	timeout := time.Second * time.Duration(settings.Timeout)
+	blacklist := model.Blacklist{
 		UserID:      newMemberID,
 		ChatID:      chatID,
-		Index:       answer.Number,
-		MsgID:       msgID,
-		ExpireAt:    time.Now().Add(time.Second * 300),
+		Answer:      answer.Token,
 		UserLink:    userLink,
-		MsgTemplate: msgTemplate,
-	})
+		MsgTemplate: MsgTemplate(chatName, settings),
+		MaxAttempts: settings.MaxAttempts,
+		CaptchaType: captchaType,
+	}
+	if settings.ChoiceMode {
+		blacklist.Choices = verifier.GenChoices(answer, model.ChoiceCount, opts)
+	}
+	msgID, err := ic.sendCaptcha(chatID, img, Caption(blacklist, timeout), Keyboard(blacklist, ic.secret), opts)
+	if err != nil {
+		return
+	}
+	blacklist.MsgID = msgID
+	blacklist.ExpireAt = time.Now().Add(timeout)
+	err = ic.blacklist.CreateItem(ctx, blacklist)
+	if err != nil {
+		// 没有验证记录的验证码无法通过，也不会超时，直接撤回以免误导用户
+		log.Println("create item failed: ", err)
+		ic.bot.DeleteMsg(chatID, msgID)
+		return
+	}
+	ic.recorder.Record(ctx, chatID, newMemberID, newMemberID, model.AuditEventJoin)
 	err = ic.queue.SendMsg(ctx, ic.countDownQueue, model.CountdownMsg{
 		ChatID: chatID,
 		UserID: newMemberID,
@@ -109,6 +292,62 @@ func (ic IdiomVerifier) OnNewMember(ctx context.Context, chatID int64, chatName
 	}
 }
 
+// sendCaptcha 与 updateCaptcha 按 opts.Animated 以动图或图片形式发送、替换验证码
+func (ic IdiomVerifier) sendCaptcha(chatID int64, img []byte, caption string, keyboard [][]model.KV, opts captcha.Options) (int, error) {
+	if opts.Animated {
+		return ic.bot.SendAnimation(chatID, img, caption, keyboard)
+	}
+	return ic.bot.SendImg(chatID, img, caption, keyboard)
+}
+
+func (ic IdiomVerifier) updateCaptcha(chatID int64, msgID int, caption string, keyboard [][]model.KV, img []byte, opts captcha.Options) {
+	if opts.Animated {
+		ic.bot.UpdateAnimation(chatID, msgID, caption, keyboard, img)
+		return
+	}
+	ic.bot.UpdatePhoto(chatID, msgID, caption, keyboard, img)
+}
+
+// errTooFewChoices 表示选择模式下重新出题时生成的选项不足，用户将无法作答
+var errTooFewChoices = xerrors.New("生成的选项不足")
+
+// reissue 为用户重新出题并更新验证码消息，消耗一次刷新次数；
+// 返回 db.ErrNotFound 表示记录的刷新次数已变化或记录已不存在，
+// 选择模式下选项不足两个时返回 errTooFewChoices，保留原题目不作修改
+func (ic IdiomVerifier) reissue(ctx context.Context, blacklist model.Blacklist, now time.Time) error {
+	_, verifier := ic.captchaOf(blacklist.CaptchaType)
+	opts := captchaOptions(GetSettings(ctx, ic.settings, blacklist.ChatID))
+	answer, img := verifier.GenRandImg(opts)
+	var choices []string
+	if len(blacklist.Choices) > 0 {
+		if choices = verifier.GenChoices(answer, len(blacklist.Choices), opts); len(choices) < 2 {
+			return errTooFewChoices
+		}
+	}
+	if err := ic.blacklist.UpdateAnswer(ctx, blacklist.ChatID, blacklist.UserID, answer.Token, choices, blacklist.Refreshes, now); err != nil {
+		return err
+	}
+	blacklist.Answer = answer.Token
+	blacklist.Choices = choices
+	blacklist.Refreshes++
+	ic.updateCaptcha(blacklist.ChatID, blacklist.MsgID,
+		Caption(blacklist, time.Until(blacklist.ExpireAt)), Keyboard(blacklist, ic.secret), img, opts,
+	)
+	return nil
+}
+
+// refreshWait 返回距离可再次刷新还需等待的秒数，不足一秒按一秒计
+func refreshWait(blacklist model.Blacklist, now time.Time) int {
+	if blacklist.RefreshAt.IsZero() {
+		return 0
+	}
+	wait := blacklist.RefreshAt.Add(time.Second * model.RefreshCooldownSecond).Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	return int((wait + time.Second - 1) / time.Second)
+}
+
 func (ic IdiomVerifier) OnCallbackQuery(ctx context.Context, chatID int64, msgID, fromUser int, callbackID, data string) {
 	switch data {
 	case model.CallbackTypeRefresh:
@@ -123,12 +362,26 @@ func (ic IdiomVerifier) OnCallbackQuery(ctx context.Context, chatID int64, msgID
 			ic.bot.AnswerCallback(callbackID, "已过期")
 			return
 		}
-		answer, img := ic.captcha.GenRandImg()
-		ic.blacklist.UpdateIdx(ctx, chatID, fromUser, answer.Number)
-		ic.bot.UpdatePhoto(chatID, blacklist.MsgID,
-			fmt.Sprintf(blacklist.UserLink+" "+blacklist.MsgTemplate, time.Until(blacklist.ExpireAt)/time.Second),
-			InlineKeyboard, img,
-		)
+		if blacklist.Refreshes >= model.MaxRefreshes {
+			ic.bot.AnswerCallback(callbackID, "刷新次数已用完")
+			return
+		}
+		now := time.Now()
+		if wait := refreshWait(*blacklist, now); wait > 0 {
+			ic.bot.AnswerCallback(callbackID, fmt.Sprintf("请等待 %d 秒后再刷新", wait))
+			return
+		}
+		if err := ic.reissue(ctx, *blacklist, now); err != nil {
+			// 记录的刷新次数已变化，说明同时有另一次刷新成功
+			if err == db.ErrNotFound {
+				ic.bot.AnswerCallback(callbackID, fmt.Sprintf("请等待 %d 秒后再刷新", model.RefreshCooldownSecond))
+				return
+			}
+			log.Println("update item failed: ", err)
+			ic.bot.AnswerCallback(callbackID, "刷新失败")
+			return
+		}
+		ic.recorder.Record(ctx, chatID, fromUser, fromUser, model.AuditEventRefresh)
 		ic.bot.AnswerCallback(callbackID, "刷新成功")
 	case model.CallbackTypeKick:
 		if !ic.bot.IsAdmin(chatID, fromUser) {
@@ -139,9 +392,18 @@ func (ic IdiomVerifier) OnCallbackQuery(ctx context.Context, chatID int64, msgID
 		if err != nil {
 			return
 		}
+		if err := ic.blacklist.DeleteItem(ctx, chatID, blacklist.UserID, blacklist.MsgID); err != nil {
+			if err == db.ErrNotFound {
+				ic.bot.AnswerCallback(callbackID, "该用户已被处理")
+				return
+			}
+			log.Println("delete item failed: ", err)
+			ic.bot.AnswerCallback(callbackID, "操作失败")
+			return
+		}
 		ic.bot.DeleteMsg(chatID, blacklist.MsgID)
 		ic.bot.Kick(chatID, blacklist.UserID, time.Unix(0, 0))
-		ic.blacklist.DeleteItem(ctx, chatID, blacklist.UserID)
+		ic.recorder.Record(ctx, chatID, blacklist.UserID, fromUser, model.AuditEventAdminKick)
 	case model.CallbackTypePassThrough:
 		if !ic.bot.IsAdmin(chatID, fromUser) {
 			ic.bot.AnswerCallback(callbackID, "无权限")
@@ -151,7 +413,16 @@ func (ic IdiomVerifier) OnCallbackQuery(ctx context.Context, chatID int64, msgID
 		if err != nil {
 			return
 		}
-		ic.bot.DeleteMsg(chatID, msgID)
-		ic.verifyOK(ctx, *blacklist)
+		if err := ic.verifyOK(ctx, *blacklist, model.AuditEventAdminPass, fromUser); err != nil {
+			if err == db.ErrNotFound {
+				ic.bot.AnswerCallback(callbackID, "该用户已被处理")
+				return
+			}
+			ic.bot.AnswerCallback(callbackID, "操作失败")
+		}
+	default:
+		if strings.HasPrefix(data, model.CallbackTypeChoice) {
+			ic.onChoice(ctx, chatID, msgID, fromUser, callbackID, data)
+		}
 	}
 }
@@ -2,7 +2,11 @@ package verifier
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,6 +31,7 @@ func TestIdiomCaptcha(t *testing.T) {
 		blacklist   *db.MockIBlacklist
 		queue       *queue.MockInterface
 		imgVerifier *captcha.MockInterface
+		recorder    Recorder
 		verifier    Interface
 	}
 
@@ -41,12 +46,14 @@ func TestIdiomCaptcha(t *testing.T) {
 		mockQueue.EXPECT().SendMsg(ctx, countdownQueue, gomock.Any(), int64(model.CaptchaRefreshSecond)).Times(1)
 
 		imgVerifier := captcha.NewMockInterface(ctrl)
-		imgVerifier.EXPECT().GenRandImg().Times(1)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Times(1)
 
-		verifier, err := NewIdiomVerifier(mockBot, mockQueue, mockBlacklist, imgVerifier)
+		recorder := newTestRecorder()
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, mockBlacklist, db.NewMemorySettings(), recorder, idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
 		assert.NoError(t, err)
 		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
 		return mockRst{
+			recorder:    recorder,
 			bot:         mockBot,
 			blacklist:   mockBlacklist,
 			queue:       mockQueue,
@@ -61,6 +68,144 @@ func TestIdiomCaptcha(t *testing.T) {
 		userEnterGroup(t, ctrl)
 	})
 
+	t.Run("按群组设置发送验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		settings := db.NewMemorySettings()
+		assert.NoError(t, settings.PutSettings(ctx, model.ChatSettings{
+			ChatID:      1,
+			Timeout:     60,
+			BanDuration: 0,
+			WelcomeMsg:  "<b>欢迎</b> 100%",
+		}))
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ int64, _ []byte, caption string, _ [][]model.KV) (int, error) {
+				assert.Contains(t, caption, "&lt;b&gt;欢迎&lt;/b&gt; 100%")
+				assert.Contains(t, caption, "60 秒后失效")
+				assert.Contains(t, caption, "永久无法再加入本群")
+				return 2, nil
+			}).Times(1)
+
+		mockBlacklist := db.NewMockIBlacklist(ctrl)
+		mockBlacklist.EXPECT().CreateItem(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, item model.Blacklist) error {
+			assert.WithinDuration(t, time.Now().Add(time.Minute), item.ExpireAt, time.Second)
+			return nil
+		}).Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, countdownQueue, gomock.Any(), int64(model.CaptchaRefreshSecond)).Times(1)
+
+		imgVerifier := captcha.NewMockInterface(ctrl)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Times(1)
+
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, mockBlacklist, settings, newTestRecorder(), idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+	})
+
+	t.Run("按群组设置使用算术验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		settings := db.NewMemorySettings()
+		chatSettings := model.DefaultChatSettings(1)
+		chatSettings.CaptchaType = model.CaptchaTypeMath
+		assert.NoError(t, settings.PutSettings(ctx, chatSettings))
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ int64, _ []byte, caption string, _ [][]model.KV) (int, error) {
+				assert.Contains(t, caption, model.CaptchaHints[model.CaptchaTypeMath])
+				return 2, nil
+			}).Times(1)
+		mockBot.EXPECT().DeleteMsg(int64(1), gomock.Any()).Times(2)
+		mockBot.EXPECT().SendMsg(int64(1), gomock.Any()).Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+		idiomVerifier := captcha.NewMockInterface(ctrl)
+		mathVerifier := captcha.NewMockInterface(ctrl)
+		mathVerifier.EXPECT().GenRandImg(captcha.Options{}).Return(model.Answer{Token: "42"}, nil).Times(1)
+		mathVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "42"}, model.Answer{String: "42"}, captcha.Options{Fuzzy: true}).Return(true).Times(1)
+
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, db.NewMemoryBlacklist(), settings, newTestRecorder(),
+			map[string]captcha.Interface{
+				model.CaptchaTypeIdiom: idiomVerifier,
+				model.CaptchaTypeMath:  mathVerifier,
+			},
+			model.CaptchaTypeIdiom,
+		)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+
+		// 验证期间切换回成语验证码，不影响已发出的算术验证码，校验选项则按最新设置
+		chatSettings = model.DefaultChatSettings(1)
+		chatSettings.FuzzyMatch = true
+		assert.NoError(t, settings.PutSettings(ctx, chatSettings))
+		verifier.Verify(ctx, int64(1), 1, 3, "42")
+	})
+
+	t.Run("群组选择的验证码未启用时使用默认验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ int64, _ []byte, caption string, _ [][]model.KV) (int, error) {
+				assert.Contains(t, caption, model.CaptchaHints[model.CaptchaTypeMath])
+				return 2, nil
+			}).Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
+
+		mathVerifier := captcha.NewMockInterface(ctrl)
+		mathVerifier.EXPECT().GenRandImg(captcha.Options{}).Return(model.Answer{Token: "42"}, nil).Times(1)
+
+		captchas := map[string]captcha.Interface{model.CaptchaTypeMath: mathVerifier}
+		_, err := NewIdiomVerifier(mockBot, mockQueue, db.NewMemoryBlacklist(), db.NewMemorySettings(), newTestRecorder(), captchas, model.CaptchaTypeIdiom)
+		assert.Error(t, err)
+
+		blacklist := db.NewMemoryBlacklist()
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, blacklist, db.NewMemorySettings(), newTestRecorder(), captchas, model.CaptchaTypeMath)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+		item, err := blacklist.GetItem(ctx, int64(1), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, model.CaptchaTypeMath, item.CaptchaType)
+	})
+
+	t.Run("按群组设置发送动图验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		settings := db.NewMemorySettings()
+		chatSettings := model.DefaultChatSettings(1)
+		chatSettings.Animated = true
+		assert.NoError(t, settings.PutSettings(ctx, chatSettings))
+		opts := captcha.Options{Animated: true}
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendAnimation(int64(1), []byte("gif"), gomock.Any(), gomock.Any()).Return(2, nil).Times(1)
+		mockBot.EXPECT().UpdateAnimation(int64(1), 2, gomock.Any(), gomock.Any(), []byte("gif")).Times(1)
+		mockBot.EXPECT().AnswerCallback("callbackID", "刷新成功").Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
+
+		imgVerifier := captcha.NewMockInterface(ctrl)
+		imgVerifier.EXPECT().GenRandImg(opts).Return(model.Answer{Token: "一心一意"}, []byte("gif")).Times(2)
+
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, db.NewMemoryBlacklist(), settings, newTestRecorder(), idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+		verifier.OnCallbackQuery(ctx, int64(1), 2, 1, "callbackID", model.CallbackTypeRefresh)
+	})
+
 	t.Run("用户发送验证失败信息", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -71,9 +216,94 @@ func TestIdiomCaptcha(t *testing.T) {
 			ChatID: int64(1),
 			UserID: 1,
 			MsgID:  2,
+			Answer: "一心一意",
 		}, nil).Times(1)
-		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Number: 0}, model.Answer{String: "WTF"}).Return(false)
+		mock.blacklist.EXPECT().IncrAttempts(ctx, int64(1), 1, 2).Return(1, nil).Times(1)
+		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "一心一意"}, model.Answer{String: "WTF"}, captcha.Options{}).Return(false)
 		mock.verifier.Verify(ctx, int64(1), 1, 2, "WTF")
+		assertEvents(t, mock.recorder, model.AuditEventWrongAnswer, model.AuditEventJoin)
+	})
+
+	t.Run("非文本消息不计入错误次数", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), []byte("img"), gomock.Any(), gomock.Any()).Return(2, nil).Times(1)
+		mockBot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+
+		mockQueue := queue.NewMockInterface(ctrl)
+		mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
+
+		imgVerifier := captcha.NewMockInterface(ctrl)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Return(model.Answer{Token: "一心一意"}, []byte("img")).Times(1)
+
+		blacklist := db.NewMemoryBlacklist()
+		recorder := newTestRecorder()
+		verifier, err := NewIdiomVerifier(mockBot, mockQueue, blacklist, db.NewMemorySettings(), recorder, idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+		verifier.Verify(ctx, int64(1), 1, 3, "")
+
+		item, err := blacklist.GetItem(ctx, int64(1), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, item.Attempts)
+		assertEvents(t, recorder, model.AuditEventJoin)
+	})
+
+	t.Run("用户回答错误后显示剩余次数", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+		mock.bot.EXPECT().UpdateCaption(int64(1), 2, gomock.Any(), InlineKeyboard).
+			Do(func(_ int64, _ int, caption string, _ [][]model.KV) {
+				assert.Contains(t, caption, "剩余尝试次数：2")
+			}).Times(1)
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			ChatID:      int64(1),
+			UserID:      1,
+			MsgID:       2,
+			Answer:      "一心一意",
+			Attempts:    2,
+			MaxAttempts: 5,
+			ExpireAt:    time.Now().Add(time.Minute),
+		}, nil).Times(1)
+		mock.blacklist.EXPECT().IncrAttempts(ctx, int64(1), 1, 2).Return(3, nil).Times(1)
+		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "一心一意"}, model.Answer{String: "WTF"}, captcha.Options{}).Return(false)
+		mock.verifier.Verify(ctx, int64(1), 1, 3, "WTF")
+	})
+
+	t.Run("用户错误次数达到上限", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+		mock.bot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+		mock.bot.EXPECT().Kick(int64(1), 1, gomock.Any()).Times(1)
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			ChatID:      int64(1),
+			UserID:      1,
+			MsgID:       2,
+			Answer:      "一心一意",
+			Attempts:    4,
+			MaxAttempts: 5,
+		}, nil).Times(1)
+		mock.blacklist.EXPECT().IncrAttempts(ctx, int64(1), 1, 2).Return(5, nil).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Times(1)
+		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "一心一意"}, model.Answer{String: "WTF"}, captcha.Options{}).Return(false)
+		mock.verifier.Verify(ctx, int64(1), 1, 3, "WTF")
+		assertEvents(t, mock.recorder, model.AuditEventAttemptKick, model.AuditEventWrongAnswer, model.AuditEventJoin)
+		stats, err := mock.recorder.Stats.ListStats(ctx, 1, model.StatsDay(time.Now()))
+		assert.NoError(t, err)
+		assert.Equal(t, []model.DailyStats{{
+			ChatID:     1,
+			Day:        model.StatsDay(time.Now()),
+			Challenged: 1,
+			LockedOut:  1,
+		}}, stats)
 	})
 
 	t.Run("用户发送验证成功信息", func(t *testing.T) {
@@ -88,13 +318,94 @@ func TestIdiomCaptcha(t *testing.T) {
 			ChatID: int64(1),
 			UserID: 1,
 			MsgID:  2,
+			Answer: "OK",
 		}, nil).Times(1)
-		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Times(1)
 		mock.queue.EXPECT().SendMsg(ctx, delMsgQueue, gomock.Any(), int64(10)).Times(1)
-		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Number: 0}, model.Answer{String: "OK"}).Return(true)
+		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "OK"}, model.Answer{String: "OK"}, captcha.Options{}).Return(true)
+		mock.verifier.Verify(ctx, int64(1), 1, 3, "OK")
+		assertEvents(t, mock.recorder, model.AuditEventPass, model.AuditEventJoin)
+	})
+
+	t.Run("用户发送验证成功信息但删除记录失败", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			ChatID: int64(1),
+			UserID: 1,
+			MsgID:  2,
+			Answer: "OK",
+		}, nil).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Return(errors.New("timeout")).Times(1)
+		mock.imgVerifier.EXPECT().VerifyAnswer(model.Answer{Token: "OK"}, model.Answer{String: "OK"}, captcha.Options{}).Return(true)
 		mock.verifier.Verify(ctx, int64(1), 1, 3, "OK")
 	})
 
+	t.Run("升级前的验证码在更换词典后重新出题", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// 旧版本以下标 0 记录答案，对应旧词典的「画蛇添足」，更换词典后下标 0 为其他成语
+		f, err := ioutil.TempFile("", "idiom-*.json")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(`[{"word": "绿树成荫"}, {"word": "画蛇添足"}]`)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		idiomCaptcha, err := captcha.NewRandIdiomCaptcha(f.Name(), "", 0, captcha.RenderOptions{}, nil)
+		assert.NoError(t, err)
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().DeleteMsg(int64(1), 3).Times(1)
+		mockBot.EXPECT().UpdatePhoto(int64(1), 2, gomock.Any(), InlineKeyboard, gomock.Any()).Times(1)
+
+		blacklist := db.NewMemoryBlacklist()
+		assert.NoError(t, blacklist.CreateItem(ctx, model.Blacklist{
+			ChatID:   1,
+			UserID:   1,
+			MsgID:    2,
+			ExpireAt: time.Now().Add(time.Minute),
+		}))
+		recorder := newTestRecorder()
+		verifier, err := NewIdiomVerifier(mockBot, queue.NewMockInterface(ctrl), blacklist, db.NewMemorySettings(), recorder, idiomCaptchas(idiomCaptcha), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		for _, v := range []string{"画蛇添足", "绿树成荫"} {
+			assert.False(t, idiomCaptcha.VerifyAnswer(model.Answer{}, model.Answer{String: v}, captcha.Options{}), v)
+		}
+		verifier.Verify(ctx, int64(1), 1, 3, "画蛇添足")
+
+		item, err := blacklist.GetItem(ctx, int64(1), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, item.Attempts)
+		assert.Equal(t, 1, item.Refreshes)
+		assert.Contains(t, []string{"绿树成荫", "画蛇添足"}, item.Answer)
+		events, err := recorder.Audit.ListEvents(ctx, 1, 1, model.MaxHistoryLimit)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("用户进群但创建记录失败", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockBot := bot.NewMockInterface(ctrl)
+		mockBot.EXPECT().SendImg(int64(1), gomock.Any(), gomock.Any(), gomock.Any()).Return(2, nil).Times(1)
+		mockBot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
+
+		mockBlacklist := db.NewMockIBlacklist(ctrl)
+		mockBlacklist.EXPECT().CreateItem(ctx, gomock.Any()).Return(errors.New("timeout")).Times(1)
+
+		imgVerifier := captcha.NewMockInterface(ctrl)
+		imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Times(1)
+
+		verifier, err := NewIdiomVerifier(mockBot, queue.NewMockInterface(ctrl), mockBlacklist, db.NewMemorySettings(), newTestRecorder(), idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+		assert.NoError(t, err)
+		verifier.OnNewMember(ctx, int64(1), "ChatName", 1, "FirstName", "LastName")
+	})
+
 	t.Run("用户进群后退群", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -106,7 +417,7 @@ func TestIdiomCaptcha(t *testing.T) {
 			UserID: 1,
 			MsgID:  2,
 		}, nil).Times(1)
-		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Times(1)
 		mock.verifier.OnLeftMember(ctx, int64(1), 1)
 	})
 
@@ -118,7 +429,7 @@ func TestIdiomCaptcha(t *testing.T) {
 		mock.bot.EXPECT().SendMsg(int64(1), gomock.Any())
 		mock.bot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
 		mock.bot.EXPECT().IsAdmin(int64(1), 3).Return(true).Times(1)
-		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Times(1)
 		mock.blacklist.EXPECT().GetItemByMsgID(ctx, int64(1), 2).Return(&model.Blacklist{
 			ChatID: 1,
 			UserID: 1,
@@ -136,13 +447,25 @@ func TestIdiomCaptcha(t *testing.T) {
 		mock.bot.EXPECT().DeleteMsg(int64(1), 2).Times(1)
 		mock.bot.EXPECT().IsAdmin(int64(1), 3).Return(true).Times(1)
 		mock.bot.EXPECT().Kick(int64(1), 1, time.Unix(0, 0)).Times(1)
-		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1).Times(1)
+		mock.blacklist.EXPECT().DeleteItem(ctx, int64(1), 1, 2).Times(1)
 		mock.blacklist.EXPECT().GetItemByMsgID(ctx, int64(1), 2).Return(&model.Blacklist{
 			ChatID: 1,
 			UserID: 1,
 			MsgID:  2,
 		}, nil).Times(1)
 		mock.verifier.OnCallbackQuery(ctx, int64(1), 2, 3, "callbackID", model.CallbackTypeKick)
+		events, err := mock.recorder.Audit.ListEvents(ctx, 1, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, model.AuditEventAdminKick, events[0].Type)
+		assert.Equal(t, 3, events[0].ActorID)
+		stats, err := mock.recorder.Stats.ListStats(ctx, 1, model.StatsDay(time.Now()))
+		assert.NoError(t, err)
+		assert.Equal(t, []model.DailyStats{{
+			ChatID:     1,
+			Day:        model.StatsDay(time.Now()),
+			Challenged: 1,
+			Kicked:     1,
+		}}, stats)
 	})
 
 	t.Run("非管理员令用户通过验证", func(t *testing.T) {
@@ -183,11 +506,169 @@ func TestIdiomCaptcha(t *testing.T) {
 		mock.bot.EXPECT().UpdatePhoto(int64(1), 2, gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 		mock.bot.EXPECT().AnswerCallback("callbackID", "刷新成功")
 		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			ChatID:   1,
+			UserID:   1,
 			MsgID:    2,
 			ExpireAt: time.Now().Add(time.Second),
 		}, nil).Times(1)
-		mock.blacklist.EXPECT().UpdateIdx(ctx, int64(1), 1, gomock.Any()).Times(1)
-		mock.imgVerifier.EXPECT().GenRandImg().Times(1)
+		mock.blacklist.EXPECT().UpdateAnswer(ctx, int64(1), 1, gomock.Any(), nil, 0, gomock.Any()).Times(1)
+		mock.imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Times(1)
+		mock.verifier.OnCallbackQuery(ctx, int64(1), 2, 1, "callbackID", model.CallbackTypeRefresh)
+	})
+
+	t.Run("用户频繁刷新验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().AnswerCallback("callbackID", "请等待 8 秒后再刷新")
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			MsgID:     2,
+			ExpireAt:  time.Now().Add(time.Minute),
+			Refreshes: 1,
+			RefreshAt: time.Now().Add(-2500 * time.Millisecond),
+		}, nil).Times(1)
+		mock.verifier.OnCallbackQuery(ctx, int64(1), 2, 1, "callbackID", model.CallbackTypeRefresh)
+	})
+
+	t.Run("用户刷新次数用完", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().AnswerCallback("callbackID", "刷新次数已用完")
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			MsgID:     2,
+			ExpireAt:  time.Now().Add(time.Minute),
+			Refreshes: model.MaxRefreshes,
+		}, nil).Times(1)
+		mock.verifier.OnCallbackQuery(ctx, int64(1), 2, 1, "callbackID", model.CallbackTypeRefresh)
+	})
+
+	t.Run("用户同时刷新验证码", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mock := userEnterGroup(t, ctrl)
+		mock.bot.EXPECT().AnswerCallback("callbackID", "请等待 10 秒后再刷新")
+		mock.blacklist.EXPECT().GetItem(ctx, int64(1), 1).Return(&model.Blacklist{
+			ChatID:   1,
+			UserID:   1,
+			MsgID:    2,
+			ExpireAt: time.Now().Add(time.Minute),
+		}, nil).Times(1)
+		mock.blacklist.EXPECT().UpdateAnswer(ctx, int64(1), 1, gomock.Any(), nil, 0, gomock.Any()).Return(db.ErrNotFound).Times(1)
+		mock.imgVerifier.EXPECT().GenRandImg(captcha.Options{}).Times(1)
 		mock.verifier.OnCallbackQuery(ctx, int64(1), 2, 1, "callbackID", model.CallbackTypeRefresh)
 	})
 }
+
+func idiomCaptchas(c captcha.Interface) map[string]captcha.Interface {
+	return map[string]captcha.Interface{model.CaptchaTypeIdiom: c}
+}
+
+func newTestRecorder() Recorder {
+	return Recorder{
+		Audit: db.NewMemoryAudit(),
+		Stats: db.NewMemoryStats(),
+	}
+}
+
+func assertEvents(t *testing.T, recorder Recorder, types ...string) {
+	events, err := recorder.Audit.ListEvents(context.Background(), 1, 1, model.MaxHistoryLimit)
+	assert.NoError(t, err)
+	rst := make([]string, 0, len(events))
+	for _, v := range events {
+		rst = append(rst, v.Type)
+	}
+	assert.Equal(t, types, rst)
+}
+
+func TestMsgTemplate(t *testing.T) {
+	settings := model.DefaultChatSettings(1)
+	assert.NotContains(t, MsgTemplate("ChatName", settings), model.PinyinHint)
+
+	settings.PinyinAnswer = true
+	assert.Contains(t, MsgTemplate("ChatName", settings), model.CaptchaHints[model.CaptchaTypeIdiom]+model.PinyinHint)
+
+	// 拼音答题仅适用于输入答案的成语验证码
+	settings.CaptchaType = model.CaptchaTypeMath
+	assert.NotContains(t, MsgTemplate("ChatName", settings), model.PinyinHint)
+	settings.CaptchaType = model.CaptchaTypeIdiom
+	// 自定义词库的词语没有拼音
+	settings.Words = []string{"一心一意", "画蛇添足", "守株待兔", "对牛弹琴"}
+	assert.NotContains(t, MsgTemplate("ChatName", settings), model.PinyinHint)
+	settings.Words = nil
+	settings.ChoiceMode = true
+	assert.NotContains(t, MsgTemplate("ChatName", settings), model.PinyinHint)
+
+	// 未注册的验证码类型使用通用提示
+	settings.CaptchaType = "unknown"
+	settings.ChoiceMode = false
+	assert.Contains(t, MsgTemplate("ChatName", settings), model.DefaultCaptchaHint)
+	settings.CaptchaType = model.CaptchaTypeIdiom
+	settings.ChoiceMode = true
+
+	// 自定义欢迎语不覆盖答题提示
+	settings.WelcomeMsg = "欢迎光临"
+	template := MsgTemplate("ChatName", settings)
+	assert.Contains(t, template, "欢迎光临")
+	assert.Contains(t, template, model.ChoiceHint)
+}
+
+func TestIdiomCaptchaRace(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var outcomes int32
+	mockBot := bot.NewMockInterface(ctrl)
+	mockBot.EXPECT().DeleteMsg(int64(1), gomock.Any()).AnyTimes()
+	mockBot.EXPECT().IsAdmin(int64(1), 3).Return(true).AnyTimes()
+	mockBot.EXPECT().AnswerCallback("callbackID", gomock.Any()).AnyTimes()
+	mockBot.EXPECT().SendMsg(int64(1), gomock.Any()).DoAndReturn(func(int64, string) (int, error) {
+		atomic.AddInt32(&outcomes, 1)
+		return 4, nil
+	}).AnyTimes()
+	mockBot.EXPECT().Kick(int64(1), 1, gomock.Any()).Do(func(int64, int, time.Time) {
+		atomic.AddInt32(&outcomes, 1)
+	}).AnyTimes()
+
+	mockQueue := queue.NewMockInterface(ctrl)
+	mockQueue.EXPECT().SendMsg(ctx, gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	imgVerifier := captcha.NewMockInterface(ctrl)
+	imgVerifier.EXPECT().VerifyAnswer(gomock.Any(), gomock.Any(), gomock.Any()).Return(true).AnyTimes()
+
+	blacklist := db.NewMemoryBlacklist()
+	verifier, err := NewIdiomVerifier(mockBot, mockQueue, blacklist, db.NewMemorySettings(), newTestRecorder(), idiomCaptchas(imgVerifier), model.CaptchaTypeIdiom)
+	assert.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		atomic.StoreInt32(&outcomes, 0)
+		assert.NoError(t, blacklist.CreateItem(ctx, model.Blacklist{
+			ChatID:   1,
+			UserID:   1,
+			MsgID:    2,
+			Answer:   "OK",
+			ExpireAt: time.Now().Add(time.Minute),
+		}))
+		wg := sync.WaitGroup{}
+		for _, f := range []func(){
+			func() { verifier.Verify(ctx, 1, 1, 5, "OK") },
+			func() { verifier.OnCallbackQuery(ctx, 1, 2, 3, "callbackID", model.CallbackTypeKick) },
+			func() { verifier.OnCallbackQuery(ctx, 1, 2, 3, "callbackID", model.CallbackTypePassThrough) },
+			func() { verifier.OnLeftMember(ctx, 1, 1) },
+		} {
+			wg.Add(1)
+			go func(f func()) {
+				defer wg.Done()
+				f()
+			}(f)
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, atomic.LoadInt32(&outcomes), int32(1), "通过与踢出只能有一方生效")
+		_, err := blacklist.GetItem(ctx, 1, 1)
+		assert.Equal(t, db.ErrNotFound, err)
+	}
+}
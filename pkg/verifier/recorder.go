@@ -0,0 +1,36 @@
+package verifier
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jqs7/drei/pkg/db"
+	"github.com/jqs7/drei/pkg/model"
+)
+
+// Recorder 记录验证过程中的事件，写入审计日志并累加对应的统计项
+type Recorder struct {
+	Audit db.IAudit
+	Stats db.IStats
+}
+
+// Record 写入失败仅记录日志，不影响验证流程
+func (r Recorder) Record(ctx context.Context, chatID int64, userID, actorID int, eventType string) {
+	now := time.Now()
+	err := r.Audit.AppendEvent(ctx, model.AuditEvent{
+		ChatID:  chatID,
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    eventType,
+		Time:    now,
+	})
+	if err != nil {
+		log.Printf("append audit event %s of %d %d failed: %+v", eventType, chatID, userID, err)
+	}
+	if field, ok := model.AuditEventStats[eventType]; ok {
+		if err := r.Stats.IncrStat(ctx, chatID, model.StatsDay(now), field); err != nil {
+			log.Printf("incr stat %s of %d failed: %+v", field, chatID, err)
+		}
+	}
+}